@@ -0,0 +1,353 @@
+package reactive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NubeIO/reactive/tracer"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------- HTTP API -------------------------- //
+// APIHandler is a http.Handler for the runtime, mount it with http.StripPrefix eg;
+//
+//	mux.Handle("/api/", http.StripPrefix("/api", NewAPIHandler(runtime)))
+//
+//	GET    /nodes                          all the nodes
+//	GET    /nodes/{uuid}                   one node
+//	GET    /nodes/{uuid}/settings          the settings of a node
+//	PUT    /nodes/{uuid}/settings          replace the settings of a node
+//	GET    /nodes/{uuid}/values            the last values of the ports of a node
+//	POST   /nodes/{uuid}/start             start a node
+//	POST   /nodes/{uuid}/stop              stop a node
+//	GET    /nodes/{uuid}/tracer            the tracer messages of a node
+//	GET    /nodes/{uuid}/tracer/messages   query the saved tracer messages of a node, see tracerQuery()
+//	GET    /connections                    all the connections
+//	POST   /connections                    add a connection
+//	DELETE /connections                    remove a connection
+//	GET    /values                         the last values of all the nodes
+//	POST   /write                          write a value to an input, see PortWrite
+
+// APIError is the JSON body of an error response
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// APINode is a node as it is returned by the api
+type APINode struct {
+	UUID        string    `json:"uuid"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	PluginName  string    `json:"pluginName"`
+	Application string    `json:"application,omitempty"`
+	ParentUUID  string    `json:"parentUUID,omitempty"`
+	Running     bool      `json:"running"`
+	Inputs      []*Port   `json:"inputs"`
+	Outputs     []*Port   `json:"outputs"`
+	Settings    *Settings `json:"settings,omitempty"`
+	Meta        *Meta     `json:"meta,omitempty"`
+	Details     *Details  `json:"details,omitempty"`
+}
+
+type APIHandler struct {
+	runtime *Runtime
+	write   http.Handler
+}
+
+func NewAPIHandler(runtime *Runtime) *APIHandler {
+	return &APIHandler{
+		runtime: runtime,
+		write:   NewPortWriteHandler(runtime),
+	}
+}
+
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "nodes":
+		h.allow(w, r, http.MethodGet, h.getNodes)
+	case len(path) == 2 && path[0] == "nodes":
+		h.allow(w, r, http.MethodGet, h.nodeHandler(path[1], h.getNode))
+	case len(path) == 3 && path[0] == "nodes":
+		h.handleNode(w, r, path[1], path[2])
+	case len(path) == 4 && path[0] == "nodes" && path[2] == "tracer" && path[3] == "messages":
+		h.allow(w, r, http.MethodGet, h.nodeHandler(path[1], h.queryTracerMessages))
+	case len(path) == 1 && path[0] == "connections":
+		h.handleConnections(w, r)
+	case len(path) == 1 && path[0] == "values":
+		h.allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, h.runtime.GetAllNodeValues())
+		})
+	case len(path) == 1 && path[0] == "write":
+		h.write.ServeHTTP(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+	}
+}
+
+func (h *APIHandler) handleNode(w http.ResponseWriter, r *http.Request, uuid, action string) {
+	switch action {
+	case "settings":
+		if r.Method == http.MethodPut {
+			h.nodeHandler(uuid, h.putSettings)(w, r)
+			return
+		}
+		h.allow(w, r, http.MethodGet, h.nodeHandler(uuid, func(w http.ResponseWriter, r *http.Request, node Node) {
+			writeJSON(w, http.StatusOK, node.GetSettings())
+		}))
+	case "values":
+		h.allow(w, r, http.MethodGet, h.nodeHandler(uuid, func(w http.ResponseWriter, r *http.Request, node Node) {
+			writeJSON(w, http.StatusOK, node.GetAllPortValues())
+		}))
+	case "start":
+		h.allow(w, r, http.MethodPost, h.nodeHandler(uuid, func(w http.ResponseWriter, r *http.Request, node Node) {
+			if err := h.runtime.StartNode(uuid); err != nil {
+				writeError(w, http.StatusConflict, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, h.apiNode(node))
+		}))
+	case "stop":
+		h.allow(w, r, http.MethodPost, h.nodeHandler(uuid, func(w http.ResponseWriter, r *http.Request, node Node) {
+			ctx, cancel := context.WithTimeout(r.Context(), defaultStopTimeout)
+			defer cancel()
+			if err := h.runtime.StopNode(ctx, uuid); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, h.apiNode(node))
+		}))
+	case "tracer":
+		h.allow(w, r, http.MethodGet, h.nodeHandler(uuid, h.getTracerMessages))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+	}
+}
+
+func (h *APIHandler) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		connections := []*Connection{}
+		for _, connection := range h.runtime.GetConnections() {
+			connections = append(connections, connection.copy())
+		}
+		writeJSON(w, http.StatusOK, connections)
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
+		return
+	}
+	connection := &Connection{}
+	if err := json.NewDecoder(r.Body).Decode(connection); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if h.runtime.GetNode(connection.TargetUUID) == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrNodeNotFound, connection.TargetUUID))
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if err := h.runtime.RemoveConnection(connection); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := h.runtime.AddConnection(connection); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, connection.copy())
+}
+
+func (h *APIHandler) getNodes(w http.ResponseWriter, r *http.Request) {
+	nodes := []*APINode{}
+	for _, node := range h.runtime.GetNodes() {
+		nodes = append(nodes, h.apiNode(node))
+	}
+	writeJSON(w, http.StatusOK, nodes)
+}
+
+func (h *APIHandler) getNode(w http.ResponseWriter, r *http.Request, node Node) {
+	writeJSON(w, http.StatusOK, h.apiNode(node))
+}
+
+func (h *APIHandler) putSettings(w http.ResponseWriter, r *http.Request, node Node) {
+	settings := &Settings{}
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// a node that does not allow a hot fix is recreated with the settings, the same as on a deploy
+	node, err := h.runtime.UpdateNodeSettings(node.GetUUID(), settings)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, node.GetSettings())
+}
+
+func (h *APIHandler) getTracerMessages(w http.ResponseWriter, r *http.Request, node Node) {
+	t := node.GetTracer()
+	if t == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("node %s has no tracer", node.GetUUID()))
+		return
+	}
+	messages, err := t.GetAllMessagesByInstance(node.GetUUID())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+func (h *APIHandler) queryTracerMessages(w http.ResponseWriter, r *http.Request, node Node) {
+	t := node.GetTracer()
+	if t == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("node %s has no tracer", node.GetUUID()))
+		return
+	}
+	query, err := tracerQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	query.InstanceUUID = node.GetUUID()
+	page, err := t.QueryMessages(query)
+	if errors.Is(err, tracer.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// tracerQuery reads a tracer query from the url eg; ?type=error&type=warning&search=timeout&from=2024-01-02T00:00:00Z
+// &limit=50&order=asc&cursor=<nextCursor>, path and key filter the same as in tracer.MessageQuery
+func tracerQuery(values url.Values) (*tracer.MessageQuery, error) {
+	query := &tracer.MessageQuery{
+		Path:        values.Get("path"),
+		Key:         values.Get("key"),
+		LoggerTypes: values["type"],
+		Search:      values.Get("search"),
+		Order:       values.Get("order"),
+		Cursor:      values.Get("cursor"),
+	}
+	for name, at := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a RFC3339 time: %w", name, err)
+			}
+			*at = t
+		}
+	}
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a number more than 0")
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+func (h *APIHandler) apiNode(node Node) *APINode {
+	return &APINode{
+		UUID:        node.GetUUID(),
+		ID:          node.GetID(),
+		Name:        node.GetNodeName(),
+		PluginName:  node.GetPluginName(),
+		Application: node.GetApplicationUse(),
+		ParentUUID:  node.GetParentUUID(),
+		Running:     h.runtime.IsRunning(node.GetUUID()),
+		Inputs:      node.GetInputs(),
+		Outputs:     node.GetOutputs(),
+		Settings:    node.GetSettings(),
+		Meta:        node.GetMeta(),
+		Details:     node.GetDetails(),
+	}
+}
+
+// nodeHandler looks up the node by its uuid and replies with a 404 if it does not exist
+func (h *APIHandler) nodeHandler(uuid string, handler func(w http.ResponseWriter, r *http.Request, node Node)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		node := h.runtime.GetNode(uuid)
+		if node == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrNodeNotFound, uuid))
+			return
+		}
+		handler(w, r, node)
+	}
+}
+
+// allow only calls the handler for the method
+func (h *APIHandler) allow(w http.ResponseWriter, r *http.Request, method string, handler http.HandlerFunc) {
+	if r.Method != method {
+		methodNotAllowed(w, method)
+		return
+	}
+	handler(w, r)
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &APIError{Error: err.Error()})
+}
+
+// errorStatus returns the http status of an error returned by the runtime
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNodeNotFound), errors.Is(err, ErrPortNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPortNotWritable):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// NewPortWriteHandler returns a http.Handler that writes a PortWrite posted as JSON and replies with the written port
+//
+//	POST {"nodeUUID": "abc", "portID": "in", "value": 22.5}
+func NewPortWriteHandler(runtime *Runtime) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		write := &PortWrite{}
+		if err := json.NewDecoder(r.Body).Decode(write); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if write.WrittenBy == "" {
+			write.WrittenBy = "http:" + r.RemoteAddr
+		}
+		port, err := runtime.WritePort(write)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, port)
+	})
+}
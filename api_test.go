@@ -0,0 +1,163 @@
+package reactive
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NubeIO/reactive/tracer"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAPIHandler(t *testing.T) {
+	runtime := NewRuntime(nil)
+	runtime.AddRegistry(newTestRegistry(t))
+	for _, uuid := range []string{"a", "b"} {
+		if err := runtime.AddNode(newTestNode(uuid, "add", runtime.GetEventBus())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runtime.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Shutdown(context.Background())
+	server := httptest.NewServer(http.StripPrefix("/api", NewAPIHandler(runtime)))
+	defer server.Close()
+
+	do := func(method, path, body string, status int, out any) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+"/api"+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("%s %s: expected status %d, got %d", method, path, status, resp.StatusCode)
+		}
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var nodes []*APINode
+	do(http.MethodGet, "/nodes", "", http.StatusOK, &nodes)
+	if len(nodes) != 2 || !nodes[0].Running || len(nodes[0].Inputs) != 1 {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+	apiErr := &APIError{}
+	do(http.MethodGet, "/nodes/missing", "", http.StatusNotFound, apiErr)
+	if apiErr.Error == "" {
+		t.Fatal("expected a JSON error body")
+	}
+	do(http.MethodDelete, "/nodes/a", "", http.StatusMethodNotAllowed, nil)
+
+	// a does not allow a hot fix so it is recreated with the settings, b is updated live
+	a, b := runtime.GetNode("a"), runtime.GetNode("b")
+	b.SetHotFix()
+	settings := &Settings{}
+	do(http.MethodPut, "/nodes/a/settings", `{"value": 10}`, http.StatusOK, settings)
+	if runtime.GetNode("a") == a || runtime.GetNode("a").GetSettings().GetFloat64Value() != 10 || !runtime.IsRunning("a") {
+		t.Fatal("node a should be recreated with the settings and started")
+	}
+	do(http.MethodPut, "/nodes/b/settings", `{"value": 5}`, http.StatusOK, settings)
+	if runtime.GetNode("b") != b || b.GetSettings().GetFloat64Value() != 5 {
+		t.Fatal("node b should be updated live")
+	}
+
+	do(http.MethodPost, "/nodes/a/stop", "", http.StatusOK, nil)
+	if runtime.IsRunning("a") {
+		t.Fatal("node was not stopped")
+	}
+	do(http.MethodPost, "/nodes/a/start", "", http.StatusOK, nil)
+	if !runtime.IsRunning("a") {
+		t.Fatal("node was not started")
+	}
+
+	connection := `{"source": "a", "sourceHandle": "out", "target": "b", "targetHandle": "in"}`
+	do(http.MethodPost, "/connections", connection, http.StatusCreated, nil)
+	var connections []*Connection
+	do(http.MethodGet, "/connections", "", http.StatusOK, &connections)
+	if len(connections) != 1 {
+		t.Fatalf("expected 1 connection, got %d", len(connections))
+	}
+	do(http.MethodDelete, "/connections", connection, http.StatusNoContent, nil)
+	do(http.MethodDelete, "/connections", connection, http.StatusNotFound, nil)
+
+	do(http.MethodPost, "/write", `{"nodeUUID": "a", "portID": "in", "value": 1}`, http.StatusOK, nil)
+	do(http.MethodGet, "/nodes/a/tracer", "", http.StatusNotFound, nil)
+	do(http.MethodGet, "/nodes/a/tracer/messages", "", http.StatusNotFound, nil)
+}
+
+func TestTracerQuery(t *testing.T) {
+	values, _ := url.ParseQuery("type=error&type=warning&search=timeout&from=2024-01-02T00:00:00Z&limit=50&order=asc")
+	query, err := tracerQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query.LoggerTypes) != 2 || query.Search != "timeout" || query.Limit != 50 || query.Order != "asc" || query.From.Year() != 2024 || !query.To.IsZero() {
+		t.Fatalf("unexpected query %+v", query)
+	}
+	for _, raw := range []string{"from=yesterday", "limit=0", "limit=ten"} {
+		values, _ := url.ParseQuery(raw)
+		if _, err := tracerQuery(values); err == nil {
+			t.Fatalf("expected an error for %s", raw)
+		}
+	}
+}
+
+func TestAPITracerMessages(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "modbus", runtime.GetEventBus())
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	store := tracer.NewMemoryStore(100)
+	// each tracer of the node has its own uuid, the messages of all of them are returned
+	for _, text := range []string{"first run", "second run"} {
+		trace := tracer.NewTracerWithStore("modbus", "modbus-driver", logger, store)
+		node.InitTracer(trace)
+		trace.Errorf(text)
+		if err := trace.SaveMessages(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer(NewAPIHandler(runtime))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/nodes/a/tracer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var messages []*tracer.Message
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Text != "first run" || messages[1].Text != "second run" {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+
+	resp, err = http.Get(server.URL + "/nodes/a/tracer/messages?order=asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	page := &tracer.MessagePage{}
+	if err := json.NewDecoder(resp.Body).Decode(page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 2 || page.Messages[0].Text != "first run" {
+		t.Fatalf("expected the query to return the messages of all the tracers of the node, got %+v", page.Messages)
+	}
+}
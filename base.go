@@ -1,6 +1,7 @@
 package reactive
 
 import (
+	"context"
 	"github.com/NubeIO/reactive/helpers"
 	message "github.com/NubeIO/reactive/tracer"
 	"github.com/NubeIO/schema"
@@ -10,33 +11,43 @@ import (
 )
 
 type BaseNode struct {
-	EventBus       *EventBus
-	ID             string
-	UUID           string
-	parentUUID     string
-	Name           string
-	pluginName     string
-	application    string // eg modbus-driver
-	Inputs         []*Port
-	Outputs        []*Port
-	LastValue      map[string]*Port
-	Bus            map[string]chan *Message
-	Connections    []*Connection
-	settings       *Settings
-	data           map[string]any
-	nodeDetails    *Details
-	Schema         *schema.Generated
-	meta           *Meta
-	options        *Options
-	mux            sync.Mutex
-	PublishOnTopic bool // if its set to true we will publish its parent info as a topic eg; myFolder/bacnetPoint
-	allowHotFix    bool
-	loaded         bool
-	runtimeNodes   map[string]Node
-	childNodes     map[string]Node
-	tracer         *message.Tracer
-	db             *gorm.DB
-	logger         *logrus.Logger
+	EventBus           *EventBus
+	ID                 string
+	UUID               string
+	parentUUID         string
+	Name               string
+	pluginName         string
+	application        string // eg modbus-driver
+	Inputs             []*Port
+	Outputs            []*Port
+	LastValue          map[string]*Port
+	sequences          map[string]uint64 // the last sequence number of each port
+	watchdogs          map[string]*watchdogState
+	watchdogRunning    bool
+	publishStates      map[string]*publishState // the publish policy of each output that has one
+	Bus                map[string]chan *Message
+	inputSubscriptions map[string]*Subscription
+	Connections        []*Connection
+	settings           *Settings
+	data               map[string]any
+	nodeDetails        *Details
+	Schema             *schema.Generated
+	meta               *Meta
+	options            *Options
+	mux                sync.Mutex
+	ctx                context.Context
+	cancel             context.CancelFunc
+	wg                 sync.WaitGroup
+	PublishOnTopic     bool // if its set to true we will publish its parent info as a topic eg; $path/myFolder/bacnetPoint
+	allowHotFix        bool
+	cycleBreaker       bool // the node delays its inputs eg; a latch, so it can be part of a feedback loop
+	loaded             bool
+	runtime            *Runtime
+	childNodes         map[string]Node
+	tracer             *message.Tracer
+	stopTrace          func() // stops publishing the messages of the tracer
+	db                 *gorm.DB
+	logger             *logrus.Logger
 }
 
 type Info struct {
@@ -90,20 +101,21 @@ func NewBaseNode(n *Info, bus *EventBus, opts *Options) *BaseNode {
 		n.NodeUUID = helpers.UUID()
 	}
 	newNode := &BaseNode{
-		EventBus:    bus,
-		ID:          n.NodeID,
-		Name:        n.Name,
-		UUID:        n.NodeUUID,
-		pluginName:  n.PluginName,
-		application: n.Application,
-		Inputs:      []*Port{},
-		Outputs:     []*Port{},
-		Bus:         make(map[string]chan *Message),
-		LastValue:   make(map[string]*Port),
-		Connections: nil,
-		allowHotFix: false,
-		childNodes:  make(map[string]Node),
-		data:        make(map[string]any),
+		EventBus:           bus,
+		ID:                 n.NodeID,
+		Name:               n.Name,
+		UUID:               n.NodeUUID,
+		pluginName:         n.PluginName,
+		application:        n.Application,
+		Inputs:             []*Port{},
+		Outputs:            []*Port{},
+		Bus:                make(map[string]chan *Message),
+		inputSubscriptions: make(map[string]*Subscription),
+		LastValue:          make(map[string]*Port),
+		Connections:        nil,
+		allowHotFix:        false,
+		childNodes:         make(map[string]Node),
+		data:               make(map[string]any),
 	}
 	newNode.setOptions(opts)
 	return newNode
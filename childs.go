@@ -5,6 +5,11 @@ func (n *BaseNode) RegisterChildNode(child Node) {
 	n.childNodes[child.GetUUID()] = child
 }
 
+// UnregisterChildNode removes a child node by its UUID
+func (n *BaseNode) UnregisterChildNode(uuid string) {
+	delete(n.childNodes, uuid)
+}
+
 // GetChildNodes returns a slice of child nodes
 func (n *BaseNode) GetChildNodes() []Node {
 	children := make([]Node, 0, len(n.childNodes))
@@ -1,76 +1,237 @@
 package reactive
 
 import (
+	"errors"
 	"fmt"
 )
 
 // Connection defines a structure for input subscriptions.
 type Connection struct {
-	SourceUUID    string        `json:"source"`
-	SourcePort    string        `json:"sourceHandle"`
-	TargetUUID    string        `json:"target"`
-	TargetPort    string        `json:"targetHandle"`
-	FlowDirection flowDirection `json:"flowDirection"` // subscriber is if it's in an input and publisher if It's for an output
-
+	SourceUUID    string            `json:"source"`
+	SourcePort    string            `json:"sourceHandle"`
+	TargetUUID    string            `json:"target"`
+	TargetPort    string            `json:"targetHandle"`
+	FlowDirection flowDirection     `json:"flowDirection"`           // subscriber is if it's in an input and publisher if It's for an output
+	Delivery      *SubscribeOptions `json:"delivery,omitempty"`      // the default is DeliveryBlock so no message is lost
+	Coerce        bool              `json:"coerce,omitempty"`        // convert the value to the data type of the input
+	OnCoerceError CoerceFailure     `json:"onCoerceError,omitempty"` // what to do with a value that can not be converted
+	subscription  *Subscription
+	transform     func(*Message) *Message // the coercion of the connection, nil if there is none
 }
 
+// CoerceFailure sets what a connection does with a value that can not be coerced to the data type of the input
+type CoerceFailure string
+
+const (
+	CoerceFailureDrop CoerceFailure = "drop" // the message is dropped, the default
+	CoerceFailureNil  CoerceFailure = "nil"  // the input gets a nil value with a bad quality
+	CoerceFailurePass CoerceFailure = "pass" // the input gets the value as it is
+)
+
 func (n *BaseNode) GetConnections() []*Connection {
 	return n.Connections
 }
 
 // ---------------------------- CONNECTIONS -------------------------- //
 
-func (n *BaseNode) AddConnection(connection *Connection) {
+// AddConnection subscribes the target input to the source port. If the node has been added to a runtime the
+// source port must exist and its data type must be compatible with the input, or the connection must set Coerce.
+func (n *BaseNode) AddConnection(connection *Connection) error {
 	if connection == nil {
-		panic("node connection can not be empty")
+		return errors.New("AddConnection() connection can not be empty")
+	}
+	if connection.TargetUUID != n.UUID {
+		return fmt.Errorf("AddConnection() connection target %s is not node %s", connection.TargetUUID, n.UUID)
+	}
+	target := n.GetInput(connection.TargetPort)
+	if target == nil {
+		return fmt.Errorf("AddConnection() node %s has no input %s", n.UUID, connection.TargetPort)
+	}
+	if n.HasConnection(connection) {
+		return fmt.Errorf("AddConnection() connection from %s:%s to %s:%s already exists",
+			connection.SourceUUID, connection.SourcePort, connection.TargetUUID, connection.TargetPort)
 	}
+	var coercion func(*Message) *Message
+	if n.runtime != nil {
+		source, err := n.runtime.sourcePort(connection)
+		if err != nil {
+			return fmt.Errorf("AddConnection() %w", err)
+		}
+		if coercion, err = connection.coercion(source, target); err != nil {
+			return fmt.Errorf("AddConnection() %w", err)
+		}
+	}
+	subscriber := connection.copy()
+	subscriber.transform = coercion
+	if err := n.subscribeConnection(subscriber); err != nil {
+		return fmt.Errorf("AddConnection() failed to add connection to: (%s-%s) %w", connection.TargetUUID, connection.TargetPort, err)
+	}
+	n.Connections = append(n.Connections, subscriber)
+	return nil
+}
 
-	sourceNodeUUID := connection.SourceUUID
-	targetNodeUUID := connection.TargetUUID
-	sourceOutput := connection.SourcePort
-	targetInput := connection.TargetPort
+// subscribeConnection subscribes the target input of the connection to the source port on the bus of the node
+func (n *BaseNode) subscribeConnection(connection *Connection) error {
+	delivery := connection.delivery()
+	coercion := connection.transform
+	received := n.inputReceived(connection.TargetPort)
+	delivery.Transform = func(msg *Message) *Message {
+		if coercion != nil {
+			if msg = coercion(msg); msg == nil {
+				return nil
+			}
+		}
+		return received(msg)
+	}
+	sourceTopic := PortTopicByUUID(connection.SourceUUID, connection.SourcePort)
+	subscription, err := n.EventBus.SubscribeChannel(sourceTopic, n.Bus[connection.TargetPort], &delivery)
+	if err != nil {
+		return err
+	}
+	connection.subscription = subscription
+	return nil
+}
 
-	sourceTopic := fmt.Sprintf("%s-%s", sourceNodeUUID, sourceOutput)
-	fmt.Printf("Add new connection type: %s from: (%s-%s) to: (%s-%s) \n", connection.FlowDirection, sourceNodeUUID, sourceOutput, targetNodeUUID, targetInput)
-	n.Bus[targetNodeUUID] = make(chan *Message, 1)
-	n.EventBus.Subscribe(sourceTopic, n.Bus[targetInput])
-	subscriber := &Connection{
-		SourceUUID:    sourceNodeUUID,
-		SourcePort:    sourceOutput,
-		TargetUUID:    targetNodeUUID,
-		TargetPort:    targetInput,
-		FlowDirection: connection.FlowDirection,
+// coercion checks the data types of the ports and returns the transform that converts the values of the source
+// to the data type of the target, it is nil if the values can be passed as they are
+func (c *Connection) coercion(source, target *Port) (func(*Message) *Message, error) {
+	if TypesCompatible(source.DataType, target.DataType) && !(c.Coerce && target.DataType == portTypeEnum) {
+		return nil, nil
+	}
+	if !c.Coerce {
+		return nil, fmt.Errorf("%s %s:%s can not be connected to %s %s:%s, set coerce to convert the value",
+			source.DataType, c.SourceUUID, c.SourcePort, target.DataType, c.TargetUUID, c.TargetPort)
+	}
+	if !CanCoerce(source.DataType, target.DataType) {
+		return nil, fmt.Errorf("%s %s:%s can not be coerced to %s %s:%s",
+			source.DataType, c.SourceUUID, c.SourcePort, target.DataType, c.TargetUUID, c.TargetPort)
 	}
+	onError := c.OnCoerceError
+	dataType, enum := target.DataType, target.Enum
+	return func(msg *Message) *Message {
+		if msg == nil || msg.Port == nil {
+			return msg
+		}
+		port := *msg.Port
+		value, err := (&Port{DataType: dataType, Enum: enum}).ConvertValue(port.Value)
+		if err != nil {
+			switch onError {
+			case CoerceFailurePass:
+				return msg
+			case CoerceFailureNil:
+				value = nil
+				port.Quality = QualityBad
+			default:
+				return nil
+			}
+		}
+		port.Value = value
+		port.DataType = dataType
+		out := *msg
+		out.Port = &port
+		return &out
+	}, nil
+}
 
-	n.Connections = append(n.Connections, subscriber)
+// RemoveConnection unsubscribes the target input from the source port and removes the connection
+func (n *BaseNode) RemoveConnection(connection *Connection) {
+	if connection == nil {
+		return
+	}
+	for i, existingConn := range n.Connections {
+		if existingConn.equal(connection) {
+			existingConn.subscription.Unsubscribe()
+			existingConn.subscription = nil
+			n.Connections = append(n.Connections[:i], n.Connections[i+1:]...)
+			return
+		}
+	}
 }
 
-func (n *BaseNode) UpdateConnections(connections []*Connection) {
-	// Iterate through existing connections
+// HasConnection returns true if the node already has the same connection
+func (n *BaseNode) HasConnection(connection *Connection) bool {
+	for _, existingConn := range n.Connections {
+		if existingConn.equal(connection) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateConnections removes the connections that are not in connections or that changed and adds the new ones
+func (n *BaseNode) UpdateConnections(connections []*Connection) error {
 	for i := len(n.Connections) - 1; i >= 0; i-- {
 		existingConn := n.Connections[i]
-		// Check if the existing connection exists in the download connections
 		found := false
 		for _, uploadedConn := range connections {
-			if existingConn.SourceUUID == uploadedConn.SourceUUID &&
-				existingConn.SourcePort == uploadedConn.SourcePort &&
-				existingConn.TargetUUID == uploadedConn.TargetUUID {
+			if existingConn.same(uploadedConn) {
 				found = true
 				break
 			}
 		}
-		// If the existing connection is not found in the download connections, remove it
 		if !found {
-			sourceTopic := fmt.Sprintf("%s-%s", existingConn.SourceUUID, existingConn.SourcePort)
-			delete(n.Bus, existingConn.TargetUUID)
-			n.EventBus.Unsubscribe(sourceTopic, n.Bus[existingConn.TargetUUID])
-			// Remove the connection from the slice
-			n.Connections = append(n.Connections[:i], n.Connections[i+1:]...)
-			fmt.Printf("Removed input subscriber for topic: %s\n", sourceTopic)
+			n.RemoveConnection(existingConn)
 		}
 	}
-	// Add or update the new connections
+	var errs []error
 	for _, connection := range connections {
-		n.AddConnection(connection)
+		if !n.HasConnection(connection) {
+			if err := n.AddConnection(connection); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copy returns a copy of the connection without its subscription
+func (c *Connection) copy() *Connection {
+	out := *c
+	out.subscription = nil
+	return &out
+}
+
+// equal compares the source and target of two connections
+func (c *Connection) equal(other *Connection) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return c.SourceUUID == other.SourceUUID &&
+		c.SourcePort == other.SourcePort &&
+		c.TargetUUID == other.TargetUUID &&
+		c.TargetPort == other.TargetPort
+}
+
+// same compares all the properties of two connections, a connection that is equal but not the same must be added
+// again so its coercion and delivery are rebuilt
+func (c *Connection) same(other *Connection) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return c.equal(other) &&
+		c.direction() == other.direction() &&
+		c.Coerce == other.Coerce &&
+		c.OnCoerceError == other.OnCoerceError &&
+		c.delivery().equal(other.delivery())
+}
+
+// direction returns the flow direction of the connection, a connection of a node input is a subscriber by default
+func (c *Connection) direction() flowDirection {
+	if c.FlowDirection == "" {
+		return DirectionSubscriber
+	}
+	return c.FlowDirection
+}
+
+// delivery returns the delivery of the connection; a connection blocks its source when the input is behind, the
+// same as a plain channel, unless it opts in to dropping messages with a Delivery policy
+func (c *Connection) delivery() SubscribeOptions {
+	delivery := SubscribeOptions{Policy: DeliveryBlock}
+	if c.Delivery != nil {
+		if c.Delivery.Policy != "" {
+			delivery.Policy = c.Delivery.Policy
+		}
+		delivery.BufferSize = c.Delivery.BufferSize
 	}
+	return delivery.withDefaults()
 }
@@ -0,0 +1,98 @@
+package reactive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectionCoercion(t *testing.T) {
+	runtime := NewRuntime(nil)
+	source := newTestNode("a", "add", runtime.GetEventBus())
+	source.NewOutputPort("text", "text", portTypeString)
+	target := newTestNode("b", "add", runtime.GetEventBus())
+	target.NewInputPort("label", "label", portTypeString)
+	for _, node := range []Node{source, target} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runtime.AddConnection(&Connection{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "label"}); err == nil {
+		t.Fatal("expected an error when connecting a float to a string")
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "a", SourcePort: "missing", TargetUUID: "b", TargetPort: "in"}); err == nil {
+		t.Fatal("expected an error when the source port does not exist")
+	}
+	if err := target.AddConnection(nil); err == nil {
+		t.Fatal("expected an error for a nil connection")
+	}
+
+	connections := []*Connection{
+		{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "label", Coerce: true},
+		{SourceUUID: "a", SourcePort: "text", TargetUUID: "b", TargetPort: "in", Coerce: true},
+	}
+	for _, connection := range connections {
+		if err := runtime.AddConnection(connection); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	receive := func(portID string) any {
+		t.Helper()
+		select {
+		case msg := <-target.Bus[portID]:
+			return msg.Port.Value
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 22.5})
+	if v := receive("label"); v != "22.5" {
+		t.Fatalf("expected the float to be coerced to a string, got %#v", v)
+	}
+	source.PublishMessage(&Port{ID: "text", Name: "text", Value: "abc"})
+	source.PublishMessage(&Port{ID: "text", Name: "text", Value: "12"})
+	if v := receive("in"); v != 12.0 {
+		t.Fatalf("expected the value that can not be parsed to be dropped, got %#v", v)
+	}
+}
+
+func TestConnectionDelivery(t *testing.T) {
+	runtime := NewRuntime(nil)
+	source := newTestNode("a", "add", runtime.GetEventBus())
+	target := newTestNode("b", "add", runtime.GetEventBus())
+	target.NewInputPort("in2", "in2", portTypeFloat)
+	for _, node := range []Node{source, target} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connections := []*Connection{
+		{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"},
+		{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in2", Delivery: &SubscribeOptions{Policy: DeliveryLatest}},
+	}
+	for _, connection := range connections {
+		if err := runtime.AddConnection(connection); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a connection only drops messages if it opts in
+	for i, want := range []DeliveryPolicy{DeliveryBlock, DeliveryLatest} {
+		if policy := target.GetConnections()[i].subscription.Stats().Policy; policy != want {
+			t.Fatalf("expected connection %d to use %s got %s", i, want, policy)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		source.PublishMessage(&Port{ID: "out", Name: "out", Value: float64(i)})
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case msg := <-target.Bus["in"]:
+			if msg.Port.Value != float64(i) {
+				t.Fatalf("expected %d got %v", i, msg.Port.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package reactive
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ---------------------------- PUBLISH POLICY -------------------------- //
+// an output with a publish policy does not publish every value it is given, eg; a noisy sensor only publishes
+// when it moved more than its deadband and at most once a second, and again every minute so subscribers know it
+// is alive. A value that is held back is not stored as the last value of the output; a change of quality is
+// always published.
+
+type PublishPolicy struct {
+	OnChange        bool          `json:"onChange,omitempty"`        // only publish a value that is not equal to the last one
+	Deadband        float64       `json:"deadband,omitempty"`        // a number must move more than this from the last published value
+	DeadbandPercent float64       `json:"deadbandPercent,omitempty"` // as Deadband, in percent of the last published value
+	MinInterval     time.Duration `json:"minInterval,omitempty"`     // publish at most once per interval, the latest value is published when it ends
+	MaxInterval     time.Duration `json:"maxInterval,omitempty"`     // publish the last value again if nothing was published for this long
+}
+
+type publishState struct {
+	policy    *PublishPolicy
+	dataType  portDataType
+	last      *Port     // the last published port
+	published time.Time // when the last port was published
+	pending   *Port     // the latest port held back by MinInterval
+	throttle  *time.Timer
+	heartbeat *time.Timer
+}
+
+// SetOutputPublishPolicy sets the publish policy of an output, a nil policy publishes every value again
+func (n *BaseNode) SetOutputPublishPolicy(id string, policy *PublishPolicy) error {
+	port := n.GetOutput(id)
+	if port == nil {
+		return fmt.Errorf("SetOutputPublishPolicy() node %s has no output %s", n.UUID, id)
+	}
+	if policy != nil {
+		if policy.Deadband < 0 || policy.DeadbandPercent < 0 || policy.MinInterval < 0 || policy.MaxInterval < 0 {
+			return errors.New("SetOutputPublishPolicy() deadband and intervals can not be negative")
+		}
+		if policy.MaxInterval > 0 && policy.MinInterval > policy.MaxInterval {
+			return errors.New("SetOutputPublishPolicy() min interval can not be more than the max interval")
+		}
+	}
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if state, ok := n.publishStates[id]; ok {
+		state.stopTimers()
+		delete(n.publishStates, id)
+	}
+	port.Publish = policy
+	if policy == nil {
+		return nil
+	}
+	if n.publishStates == nil {
+		n.publishStates = make(map[string]*publishState)
+	}
+	n.publishStates[id] = &publishState{policy: policy, dataType: port.DataType}
+	return nil
+}
+
+// publishPort publishes the port if its publish policy lets it through, it is used by PublishMessage() and scan mode
+func (n *BaseNode) publishPort(port *Port) {
+	n.mux.Lock()
+	if state, ok := n.publishStates[port.ID]; ok {
+		if !state.changed(port) {
+			state.pending = nil // the value is back within the deadband of the last published value
+			n.mux.Unlock()
+			return
+		}
+		if wait := state.policy.MinInterval - time.Since(state.published); state.last != nil && wait > 0 {
+			pending := *port
+			state.pending = &pending
+			if state.throttle == nil {
+				state.throttle = time.AfterFunc(wait, func() { n.publishPending(port.ID, state) })
+			}
+			n.mux.Unlock()
+			return
+		}
+		state.pending = nil
+	}
+	n.mux.Unlock()
+	n.sendPort(port)
+}
+
+// sendPort sets the last value of the port and publishes it. A copy of the port is stamped and published so a port
+// the caller publishes again gets a new timestamp and quality, and no subscriber shares the port of the caller.
+func (n *BaseNode) sendPort(from *Port) {
+	port := *from
+	port.Value = copyValue(from.Value)
+	n.stamp(&port)
+	n.SetLastValue(&port)
+	topic := n.setPortTopic(port.ID)
+	m := &Message{
+		Port:     &port,
+		NodeUUID: n.GetUUID(),
+		NodeID:   n.GetID(),
+	}
+	n.EventBus.Publish(topic, m)
+	if n.logger != nil {
+		n.logger.Debugf("Published message from node: (name: %s uuid: %s) to topic: %s value %v", n.GetID(), n.GetUUID(), topic, printValue(port.Value))
+	}
+	if n.PublishOnTopic {
+		n.EventBus.Publish(n.setPathTopic(port.ID), m)
+	}
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	state, ok := n.publishStates[port.ID]
+	if !ok {
+		return
+	}
+	last := port
+	state.last, state.published = &last, time.Now()
+	if state.policy.MaxInterval > 0 {
+		if state.heartbeat != nil {
+			state.heartbeat.Stop()
+		}
+		state.heartbeat = time.AfterFunc(state.policy.MaxInterval, func() { n.publishHeartbeat(port.ID, state) })
+	}
+}
+
+// publishPending publishes the port that was held back when the min interval ends
+func (n *BaseNode) publishPending(id string, state *publishState) {
+	n.mux.Lock()
+	if n.publishStates[id] != state || n.stopped() {
+		n.mux.Unlock()
+		return
+	}
+	pending := state.pending
+	state.pending, state.throttle = nil, nil
+	n.mux.Unlock()
+	if pending != nil {
+		n.sendPort(pending)
+	}
+}
+
+// publishHeartbeat publishes the last value again when nothing was published for the max interval
+func (n *BaseNode) publishHeartbeat(id string, state *publishState) {
+	n.mux.Lock()
+	if n.publishStates[id] != state || n.stopped() || state.last == nil || time.Since(state.published) < state.policy.MaxInterval {
+		n.mux.Unlock()
+		return
+	}
+	port := *state.last
+	n.mux.Unlock()
+	port.LastUpdated = time.Now().Format(time.RFC3339)
+	n.sendPort(&port)
+}
+
+// stopped returns true if the node was started and then stopped, n.mux must be held
+func (n *BaseNode) stopped() bool {
+	return n.ctx != nil && n.ctx.Err() != nil
+}
+
+// stopPublishTimers stops the held back and heartbeat publishes when the node is stopped or deleted
+func (n *BaseNode) stopPublishTimers() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	for _, state := range n.publishStates {
+		state.stopTimers()
+	}
+}
+
+func (s *publishState) stopTimers() {
+	if s.throttle != nil {
+		s.throttle.Stop()
+		s.throttle = nil
+	}
+	if s.heartbeat != nil {
+		s.heartbeat.Stop()
+		s.heartbeat = nil
+	}
+	s.pending = nil
+}
+
+// reset forgets the last published port so the policy starts again, the timers must be stopped
+func (s *publishState) reset() {
+	s.stopTimers()
+	s.last, s.published = nil, time.Time{}
+}
+
+// changed returns true if the port is different enough from the last published port to be published
+func (s *publishState) changed(port *Port) bool {
+	if s.last == nil || qualityOf(port) != qualityOf(s.last) {
+		return true
+	}
+	if s.policy.Deadband > 0 || s.policy.DeadbandPercent > 0 {
+		value, err := toFloat(port.Value)
+		if err == nil {
+			last, err := toFloat(s.last.Value)
+			if err == nil {
+				band := math.Max(s.policy.Deadband, math.Abs(last)*s.policy.DeadbandPercent/100)
+				return math.Abs(value-last) > band
+			}
+		}
+	}
+	if s.policy.OnChange || s.policy.Deadband > 0 || s.policy.DeadbandPercent > 0 {
+		return !EqualValues(s.dataType, port.Value, s.last.Value)
+	}
+	return true
+}
+
+// qualityOf returns the quality of the port, a port that has not been stamped yet is good
+func qualityOf(port *Port) Quality {
+	if port.Quality == "" {
+		return QualityGood
+	}
+	return port.Quality
+}
@@ -0,0 +1,116 @@
+package reactive
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishPolicy(t *testing.T) {
+	runtime := NewRuntime(nil)
+	source := newTestNode("a", "modbus", runtime.GetEventBus())
+	if err := runtime.AddNode(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetOutputPublishPolicy("missing", &PublishPolicy{OnChange: true}); err == nil {
+		t.Fatal("expected an error for a missing output")
+	}
+	if err := source.SetOutputPublishPolicy("out", &PublishPolicy{MinInterval: time.Second, MaxInterval: time.Millisecond}); err == nil {
+		t.Fatal("expected an error for a min interval more than the max interval")
+	}
+	if err := source.SetOutputPublishPolicy("out", &PublishPolicy{Deadband: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan *Message, 10)
+	sub, err := runtime.GetEventBus().SubscribeChannel(PortTopicByUUID("a", "out"), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	receive := func() *Port {
+		t.Helper()
+		select {
+		case msg := <-messages:
+			return msg.Port
+		case <-time.After(time.Second):
+			t.Fatal("message was not published")
+		}
+		return nil
+	}
+	none := func(wait time.Duration) {
+		t.Helper()
+		select {
+		case msg := <-messages:
+			t.Fatalf("unexpected message %+v", msg.Port)
+		case <-time.After(wait):
+		}
+	}
+
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 10.0})
+	if port := receive(); port.Value != 10.0 {
+		t.Fatalf("unexpected port %+v", port)
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 10.4})
+	none(20 * time.Millisecond)
+	if last, _ := source.GetPortValue("out"); last.Value != 10.0 {
+		t.Fatalf("a value within the deadband should not be the last value %+v", last)
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 10.4, Quality: QualityCommFail})
+	if port := receive(); port.Quality != QualityCommFail {
+		t.Fatalf("a change of quality should be published %+v", port)
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 11.0, Quality: QualityCommFail})
+	if port := receive(); port.Value != 11.0 {
+		t.Fatalf("unexpected port %+v", port)
+	}
+
+	// the latest of the values held back by the min interval is published when it ends
+	if err := source.SetOutputPublishPolicy("out", &PublishPolicy{MinInterval: 50 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 1.0})
+	receive()
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 2.0})
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 3.0})
+	if port := receive(); port.Value != 3.0 {
+		t.Fatalf("expected the latest held back value %+v", port)
+	}
+	none(80 * time.Millisecond)
+
+	// the last value is published again after the max interval
+	if err := source.SetOutputPublishPolicy("out", &PublishPolicy{OnChange: true, MaxInterval: 30 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 5.0})
+	first := receive()
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 5.0})
+	heartbeat := receive()
+	if heartbeat.Value != 5.0 || heartbeat.Sequence <= first.Sequence {
+		t.Fatalf("unexpected heartbeat %+v", heartbeat)
+	}
+
+	// a node that is stopped and started again publishes its next value and restarts its heartbeat
+	if err := source.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for len(messages) > 0 {
+		<-messages
+	}
+	if err := source.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 5.0})
+	if port := receive(); port.Value != 5.0 {
+		t.Fatalf("expected the first value after a restart to be published %+v", port)
+	}
+	if heartbeat := receive(); heartbeat.Value != 5.0 {
+		t.Fatalf("unexpected heartbeat after a restart %+v", heartbeat)
+	}
+	if err := source.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	none(60 * time.Millisecond)
+}
@@ -0,0 +1,459 @@
+package reactive
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------- DATA TYPES -------------------------- //
+// the value of a port is converted to the Go type of its data type, which is encoded in JSON as
+//   - float      float64      number
+//   - int        int64        number, a float with a fraction is not an int
+//   - string     string       string
+//   - bool       bool         true/false, also from "on"/"off", "1"/"0" and numbers
+//   - json       map[string]any  object, also from a string holding a JSON object
+//   - array      []any        array, also from a string holding a JSON array
+//   - timestamp  time.Time    RFC3339 string, also from a number of unix seconds
+//   - enum       string       one of the allowed values of the port, also from the index of the value
+//   - bytes      []byte       base64 string, also from an array of numbers 0-255
+//   - any        the value as it is
+
+// PortDataTypes returns all the data types a port can have
+func PortDataTypes() []portDataType {
+	return []portDataType{
+		portTypeAny,
+		portTypeFloat,
+		portTypeInt,
+		portTypeString,
+		portTypeBool,
+		portTypeJSON,
+		portTypeArray,
+		portTypeTimestamp,
+		portTypeEnum,
+		portTypeBytes,
+	}
+}
+
+// coercions are the data types a value can be coerced to by a connection, a coercion can still fail at runtime
+// eg; a string that is not a number can not be coerced to a float
+var coercions = map[portDataType][]portDataType{
+	portTypeFloat:     {portTypeInt, portTypeString, portTypeBool},
+	portTypeInt:       {portTypeFloat, portTypeString, portTypeBool, portTypeEnum, portTypeTimestamp},
+	portTypeString:    {portTypeFloat, portTypeInt, portTypeBool, portTypeJSON, portTypeArray, portTypeTimestamp, portTypeEnum, portTypeBytes},
+	portTypeBool:      {portTypeFloat, portTypeInt, portTypeString},
+	portTypeJSON:      {portTypeString},
+	portTypeArray:     {portTypeString, portTypeBytes},
+	portTypeTimestamp: {portTypeString},
+	portTypeEnum:      {portTypeString},
+	portTypeBytes:     {portTypeString},
+}
+
+// TypesCompatible returns true if a value of one data type can be passed as it is to a port of the other type
+func TypesCompatible(from, to portDataType) bool {
+	return from == to || from == portTypeAny || to == portTypeAny || from == "" || to == ""
+}
+
+// CanCoerce returns true if a value of one data type can be converted to the other type
+func CanCoerce(from, to portDataType) bool {
+	if TypesCompatible(from, to) {
+		return true
+	}
+	for _, dataType := range coercions[from] {
+		if dataType == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertValue converts a value to the data type of a port, a nil value is kept as nil. An enum is not checked
+// against its allowed values, use Port.ConvertValue() for that.
+func ConvertValue(value any, dataType portDataType) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch dataType {
+	case portTypeFloat:
+		return toFloat(value)
+	case portTypeInt:
+		return toInt(value)
+	case portTypeString:
+		return toString(value)
+	case portTypeBool:
+		return toBool(value)
+	case portTypeJSON:
+		return toJSONObject(value)
+	case portTypeArray:
+		return toArray(value)
+	case portTypeTimestamp:
+		return toTimestamp(value)
+	case portTypeEnum:
+		return toEnum(value, nil)
+	case portTypeBytes:
+		return toBytes(value)
+	case portTypeAny, "":
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown data type %s", dataType)
+}
+
+// ConvertValue converts a value to the data type of the port, see ConvertValue()
+func (p *Port) ConvertValue(value any) (any, error) {
+	if p.DataType == portTypeEnum && value != nil {
+		return toEnum(value, p.Enum)
+	}
+	return ConvertValue(value, p.DataType)
+}
+
+// EqualValues returns true if both values are the same after they are converted to the data type
+func EqualValues(dataType portDataType, a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	x, err := ConvertValue(a, dataType)
+	if err != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	y, err := ConvertValue(b, dataType)
+	if err != nil {
+		return false
+	}
+	switch v := x.(type) {
+	case time.Time:
+		return v.Equal(y.(time.Time))
+	case []byte:
+		return bytes.Equal(v, y.([]byte))
+	case map[string]any, []any:
+		return reflect.DeepEqual(normalizeJSON(x), normalizeJSON(y))
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// CompareValues returns -1, 0 or 1 when a is less than, equal to or greater than b. Only float, int, string, bool
+// and timestamp values can be ordered, false is less than true.
+func CompareValues(dataType portDataType, a, b any) (int, error) {
+	x, err := ConvertValue(a, dataType)
+	if err != nil {
+		return 0, err
+	}
+	y, err := ConvertValue(b, dataType)
+	if err != nil {
+		return 0, err
+	}
+	if x == nil || y == nil {
+		return 0, fmt.Errorf("a nil value can not be compared")
+	}
+	switch v := x.(type) {
+	case float64:
+		return compareOrdered(v, y.(float64)), nil
+	case int64:
+		return compareOrdered(v, y.(int64)), nil
+	case string:
+		if dataType == portTypeEnum {
+			break
+		}
+		return strings.Compare(v, y.(string)), nil
+	case bool:
+		return compareOrdered(boolToInt(v), boolToInt(y.(bool))), nil
+	case time.Time:
+		return v.Compare(y.(time.Time)), nil
+	}
+	return 0, fmt.Errorf("values of data type %s can not be ordered", dataType)
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// normalizeJSON round trips a value through JSON so numbers of any Go type compare the same
+func normalizeJSON(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+	return out
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a float", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("%T can not be converted to a float", value)
+}
+
+func toInt(value any) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return toInt(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%d is too large for an int", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return toInt(v.String())
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return toInt(f)
+		}
+		return 0, fmt.Errorf("%q is not an int", v)
+	}
+	f, err := toFloat(value)
+	if err != nil {
+		return 0, fmt.Errorf("%T can not be converted to an int", value)
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v is not an int", f)
+	}
+	return int64(f), nil
+}
+
+func toString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return fmt.Sprintf("%v", v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("%T can not be converted to a string", value)
+}
+
+func toBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "on":
+			return true, nil
+		case "false", "0", "off":
+			return false, nil
+		}
+		return false, fmt.Errorf("%q is not a bool", v)
+	}
+	f, err := toFloat(value)
+	if err != nil {
+		return false, fmt.Errorf("%T can not be converted to a bool", value)
+	}
+	return f != 0, nil
+}
+
+func toJSONObject(value any) (map[string]any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, nil
+	case string:
+		out := map[string]any{}
+		if err := json.Unmarshal([]byte(v), &out); err != nil {
+			return nil, fmt.Errorf("%q is not a JSON object", v)
+		}
+		return out, nil
+	case []byte:
+		return toJSONObject(string(v))
+	}
+	out, ok := normalizeJSON(value).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%T can not be converted to a JSON object", value)
+	}
+	return out, nil
+}
+
+func toArray(value any) ([]any, error) {
+	switch v := value.(type) {
+	case []any:
+		return v, nil
+	case string:
+		var out []any
+		if err := json.Unmarshal([]byte(v), &out); err != nil {
+			return nil, fmt.Errorf("%q is not a JSON array", v)
+		}
+		return out, nil
+	}
+	if kind := reflect.TypeOf(value).Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return nil, fmt.Errorf("%T can not be converted to an array", value)
+	}
+	out, ok := normalizeJSON(value).([]any)
+	if !ok {
+		return nil, fmt.Errorf("%T can not be converted to an array", value)
+	}
+	return out, nil
+}
+
+func toTimestamp(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not an RFC3339 timestamp", v)
+		}
+		return t, nil
+	case bool:
+	default:
+		seconds, err := toFloat(value)
+		if err != nil {
+			break
+		}
+		whole, fraction := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(fraction*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%T can not be converted to a timestamp", value)
+}
+
+// toEnum returns the value if it is one of the allowed values, a number is the index of an allowed value
+func toEnum(value any, enum []string) (string, error) {
+	if s, ok := value.(string); ok {
+		if len(enum) == 0 {
+			return s, nil
+		}
+		for _, allowed := range enum {
+			if s == allowed {
+				return s, nil
+			}
+		}
+		if i, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && i >= 0 && i < len(enum) {
+			return enum[i], nil
+		}
+		return "", fmt.Errorf("%q is not one of %s", s, strings.Join(enum, ", "))
+	}
+	if _, ok := value.(bool); !ok {
+		if i, err := toInt(value); err == nil {
+			if i >= 0 && i < int64(len(enum)) {
+				return enum[i], nil
+			}
+			if len(enum) == 0 {
+				return strconv.FormatInt(i, 10), nil
+			}
+			return "", fmt.Errorf("%d is not an index of %s", i, strings.Join(enum, ", "))
+		}
+	}
+	return "", fmt.Errorf("%T can not be converted to an enum", value)
+}
+
+func toBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		out, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a base64 string", v)
+		}
+		return out, nil
+	case []any:
+		out := make([]byte, len(v))
+		for i, item := range v {
+			b, err := toInt(item)
+			if err != nil || b < 0 || b > math.MaxUint8 {
+				return nil, fmt.Errorf("%v is not a byte", item)
+			}
+			out[i] = byte(b)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%T can not be converted to bytes", value)
+}
@@ -0,0 +1,76 @@
+package reactive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertValue(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		dataType portDataType
+		value    any
+		want     any
+		fail     bool
+	}{
+		{portTypeFloat, "22.5", 22.5, false},
+		{portTypeFloat, true, 1.0, false},
+		{portTypeInt, 22.0, int64(22), false},
+		{portTypeInt, "40001", int64(40001), false},
+		{portTypeInt, 22.5, nil, true},
+		{portTypeString, 22.5, "22.5", false},
+		{portTypeBool, "on", true, false},
+		{portTypeBool, "maybe", nil, true},
+		{portTypeJSON, `{"a": 1}`, map[string]any{"a": 1.0}, false},
+		{portTypeJSON, []any{1}, nil, true},
+		{portTypeArray, []int{1, 2}, []any{1.0, 2.0}, false},
+		{portTypeArray, `[true]`, []any{true}, false},
+		{portTypeTimestamp, "2024-01-02T03:04:05Z", ts, false},
+		{portTypeTimestamp, float64(ts.Unix()), ts, false},
+		{portTypeBytes, "AQI=", []byte{1, 2}, false},
+		{portTypeBytes, []any{1.0, 256.0}, nil, true},
+		{portTypeAny, []int{1}, []int{1}, false},
+	}
+	for _, test := range tests {
+		got, err := ConvertValue(test.value, test.dataType)
+		if test.fail {
+			if err == nil {
+				t.Fatalf("%s %v: expected an error, got %v", test.dataType, test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s %v: %s", test.dataType, test.value, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%s %v: expected %#v, got %#v", test.dataType, test.value, test.want, got)
+		}
+	}
+
+	port := &Port{DataType: portTypeEnum, Enum: []string{"inactive", "active"}}
+	if v, err := port.ConvertValue(1.0); err != nil || v != "active" {
+		t.Fatalf("expected the enum index to select active, got %v %v", v, err)
+	}
+	if _, err := port.ConvertValue("fault"); err == nil {
+		t.Fatal("expected an error for a value that is not allowed")
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	if !EqualValues(portTypeFloat, 1, "1.0") || EqualValues(portTypeFloat, 1, 2) {
+		t.Fatal("unexpected float equality")
+	}
+	if !EqualValues(portTypeJSON, map[string]any{"a": 1}, `{"a": 1}`) {
+		t.Fatal("unexpected json equality")
+	}
+	if !EqualValues(portTypeTimestamp, "2024-01-02T13:04:05+10:00", "2024-01-02T03:04:05Z") {
+		t.Fatal("timestamps in different zones must be equal")
+	}
+	if c, err := CompareValues(portTypeInt, 2, "10"); err != nil || c != -1 {
+		t.Fatalf("expected 2 < 10, got %d %v", c, err)
+	}
+	if _, err := CompareValues(portTypeArray, []any{1}, []any{2}); err == nil {
+		t.Fatal("arrays can not be ordered")
+	}
+}
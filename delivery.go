@@ -0,0 +1,242 @@
+package reactive
+
+import (
+	"sync"
+)
+
+// ---------------------------- DELIVERY -------------------------- //
+// each subscriber has its own bounded buffer and one goroutine that delivers the buffered messages in the
+// order they were published, the delivery policy decides what happens when the buffer is full
+
+type DeliveryPolicy string
+
+const (
+	DeliveryBlock      DeliveryPolicy = "block"       // the publisher waits until there is room in the buffer
+	DeliveryDropOldest DeliveryPolicy = "drop-oldest" // the oldest buffered message is dropped to make room
+	DeliveryDropNewest DeliveryPolicy = "drop-newest" // the new message is dropped
+	DeliveryLatest     DeliveryPolicy = "latest"      // only the latest message of each topic is buffered
+)
+
+// DefaultBufferSize is the buffer size of a subscriber that does not set one
+const DefaultBufferSize = 64
+
+// SubscribeOptions sets how messages are delivered to a subscriber, the default is DeliveryDropOldest. The
+// connections between nodes default to DeliveryBlock, see Connection.Delivery.
+type SubscribeOptions struct {
+	Policy     DeliveryPolicy `json:"policy,omitempty"`
+	BufferSize int            `json:"bufferSize,omitempty"`
+	// Transform changes a message before it is delivered, the message is dropped if it returns nil
+	Transform func(*Message) *Message `json:"-"`
+}
+
+func (o *SubscribeOptions) withDefaults() SubscribeOptions {
+	out := SubscribeOptions{Policy: DeliveryDropOldest, BufferSize: DefaultBufferSize}
+	if o == nil {
+		return out
+	}
+	switch o.Policy {
+	case DeliveryBlock, DeliveryDropOldest, DeliveryDropNewest, DeliveryLatest:
+		out.Policy = o.Policy
+	}
+	if o.BufferSize > 0 {
+		out.BufferSize = o.BufferSize
+	}
+	out.Transform = o.Transform
+	return out
+}
+
+// equal compares the policy and buffer size of the options, the transform is not compared
+func (o SubscribeOptions) equal(other SubscribeOptions) bool {
+	return o.Policy == other.Policy && o.BufferSize == other.BufferSize
+}
+
+// SubscriptionStats are the delivery counters of a subscriber
+type SubscriptionStats struct {
+	Topic     string         `json:"topic"`
+	Policy    DeliveryPolicy `json:"policy"`
+	Buffered  int            `json:"buffered"`
+	Delivered uint64         `json:"delivered"`
+	Dropped   uint64         `json:"dropped"`
+	Coalesced uint64         `json:"coalesced"` // messages replaced by a newer message of the same topic
+	Overflows uint64         `json:"overflows"` // times a message was published while the buffer was full
+}
+
+type queuedMessage struct {
+	topic   string
+	message *Message
+}
+
+type subscriber struct {
+	topic     string
+	handler   func(*Message)
+	ch        chan *Message // set for a channel subscriber, a send to it can be cancelled by close()
+	opts      SubscribeOptions
+	bus       *EventBus
+	mu        sync.Mutex
+	space     *sync.Cond // signalled when a message leaves the buffer
+	queue     []*queuedMessage
+	notify    chan struct{}
+	done      chan struct{}
+	closed    bool
+	delivered uint64
+	dropped   uint64
+	coalesced uint64
+	overflows uint64
+}
+
+func newSubscriber(bus *EventBus, topic string, handler func(*Message), ch chan *Message, opts *SubscribeOptions) *subscriber {
+	s := &subscriber{
+		topic:   topic,
+		handler: handler,
+		ch:      ch,
+		opts:    opts.withDefaults(),
+		bus:     bus,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	s.space = sync.NewCond(&s.mu)
+	go s.run()
+	return s
+}
+
+// enqueue buffers the message by the delivery policy, only DeliveryBlock can make the caller wait
+func (s *subscriber) enqueue(topic string, message *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.opts.Policy == DeliveryLatest {
+		for _, queued := range s.queue {
+			if queued.topic == topic {
+				queued.message = message
+				s.coalesced++
+				return
+			}
+		}
+	}
+	if len(s.queue) >= s.opts.BufferSize {
+		s.overflows++
+		switch s.opts.Policy {
+		case DeliveryBlock:
+			for len(s.queue) >= s.opts.BufferSize && !s.closed {
+				s.space.Wait()
+			}
+			if s.closed {
+				return
+			}
+		case DeliveryDropNewest:
+			s.dropped++
+			return
+		default:
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.dropped++
+			s.bus.inFlight.Add(-1)
+		}
+	}
+	s.queue = append(s.queue, &queuedMessage{topic: topic, message: message})
+	s.bus.inFlight.Add(1)
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) next() *queuedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil
+	}
+	queued := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	s.space.Signal()
+	return queued
+}
+
+// run delivers the buffered messages one at a time so they arrive in the order they were published
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.bus.closed:
+			s.close()
+			return
+		case <-s.notify:
+		}
+		for queued := s.next(); queued != nil; queued = s.next() {
+			message := queued.message
+			if s.opts.Transform != nil {
+				message = s.opts.Transform(message)
+			}
+			if message == nil {
+				s.mu.Lock()
+				s.dropped++
+				s.mu.Unlock()
+				s.bus.inFlight.Add(-1)
+				continue
+			}
+			if !s.deliver(message) {
+				s.bus.inFlight.Add(-1)
+				s.close()
+				return
+			}
+			s.mu.Lock()
+			s.delivered++
+			s.mu.Unlock()
+			s.bus.inFlight.Add(-1)
+		}
+	}
+}
+
+// deliver passes the message to the handler or channel, it returns false if the subscriber was closed first
+func (s *subscriber) deliver(message *Message) bool {
+	if s.ch == nil {
+		select {
+		case <-s.done:
+			return false
+		default:
+		}
+		s.handler(message)
+		return true
+	}
+	select {
+	case s.ch <- message:
+		return true
+	case <-s.done:
+		return false
+	case <-s.bus.closed:
+		return false
+	}
+}
+
+// close stops the delivery goroutine and discards the buffered messages, a channel is never closed as it is owned by the caller
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.bus.inFlight.Add(-int64(len(s.queue)))
+	s.queue = nil
+	close(s.done)
+	s.space.Broadcast()
+}
+
+func (s *subscriber) stats() *SubscriptionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &SubscriptionStats{
+		Topic:     s.topic,
+		Policy:    s.opts.Policy,
+		Buffered:  len(s.queue),
+		Delivered: s.delivered,
+		Dropped:   s.dropped,
+		Coalesced: s.coalesced,
+		Overflows: s.overflows,
+	}
+}
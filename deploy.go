@@ -0,0 +1,287 @@
+package reactive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DeployReport is the delta that Deploy() applied to the running flow
+type DeployReport struct {
+	AddedNodes         []string      `json:"addedNodes"`
+	RemovedNodes       []string      `json:"removedNodes"`
+	UpdatedNodes       []string      `json:"updatedNodes"`  // changes applied live, settings only if the node allows HotFix()
+	ReplacedNodes      []string      `json:"replacedNodes"` // node was recreated to apply the changes
+	UnchangedNodes     int           `json:"unchangedNodes"`
+	AddedConnections   []*Connection `json:"addedConnections"`
+	RemovedConnections []*Connection `json:"removedConnections"`
+}
+
+// HasChanges returns false if the deployed flow was the same as the running flow
+func (d *DeployReport) HasChanges() bool {
+	return len(d.AddedNodes) > 0 || len(d.RemovedNodes) > 0 || len(d.UpdatedNodes) > 0 || len(d.ReplacedNodes) > 0 ||
+		len(d.AddedConnections) > 0 || len(d.RemovedConnections) > 0
+}
+
+func newDeployReport() *DeployReport {
+	return &DeployReport{
+		AddedNodes:         []string{},
+		RemovedNodes:       []string{},
+		UpdatedNodes:       []string{},
+		ReplacedNodes:      []string{},
+		AddedConnections:   []*Connection{},
+		RemovedConnections: []*Connection{},
+	}
+}
+
+// Deploy diffs the flow against the running flow and only applies the changes; nodes that are new are added,
+// nodes that are not in the flow are removed and changed nodes are updated live. A node whose settings changed
+// is only updated live if it allows HotFix(), else it is recreated, as is a node whose plugin or id changed.
+func (r *Runtime) Deploy(flow *Flow) (*DeployReport, error) {
+	if err := r.validateDeploy(flow); err != nil {
+		return nil, fmt.Errorf("Deploy() %w", err)
+	}
+	report := newDeployReport()
+
+	current := make(map[string]Node)
+	for _, node := range r.GetNodes() {
+		current[node.GetUUID()] = node
+	}
+	var added, replaced, updated []*FlowNode
+	inFlow := make(map[string]bool, len(flow.Nodes))
+	for _, fn := range flow.Nodes {
+		inFlow[fn.UUID] = true
+		node, exists := current[fn.UUID]
+		switch {
+		case !exists:
+			added = append(added, fn)
+		case node.GetPluginName() != fn.PluginName || node.GetID() != fn.ID:
+			replaced = append(replaced, fn)
+		case !jsonEqual(node.GetSettings(), fn.Settings):
+			if node.HotFix() {
+				updated = append(updated, fn)
+			} else {
+				replaced = append(replaced, fn)
+			}
+		case node.GetNodeName() != fn.Name || !jsonEqual(node.GetMeta(), fn.Meta) || (fn.Details != nil && !jsonEqual(node.GetDetails(), fn.Details)):
+			updated = append(updated, fn)
+		default:
+			report.UnchangedNodes++
+		}
+	}
+
+	running := r.GetConnections()
+	for _, connection := range running {
+		if !containsConnection(flow.Connections, connection) {
+			report.RemovedConnections = append(report.RemovedConnections, connection.copy())
+		}
+	}
+	for _, connection := range flow.Connections {
+		if !containsConnection(running, connection) {
+			report.AddedConnections = append(report.AddedConnections, connection.copy())
+		}
+	}
+
+	var errs []error
+	for _, connection := range report.RemovedConnections {
+		if target := r.GetNode(connection.TargetUUID); target != nil {
+			target.RemoveConnection(connection)
+		}
+	}
+	for _, node := range r.GetNodes() {
+		if !inFlow[node.GetUUID()] {
+			if err := r.RemoveNode(node.GetUUID()); err != nil {
+				errs = append(errs, err)
+			}
+			report.RemovedNodes = append(report.RemovedNodes, node.GetUUID())
+		}
+	}
+	// the connections to and from a replaced node are added again from the flow below
+	for _, fn := range replaced {
+		if _, err := r.replaceNode(fn); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		report.ReplacedNodes = append(report.ReplacedNodes, fn.UUID)
+	}
+	for _, fn := range added {
+		node, err := r.newFlowNode(fn)
+		if err == nil {
+			err = r.AddNode(node)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		report.AddedNodes = append(report.AddedNodes, fn.UUID)
+	}
+	for _, fn := range updated {
+		r.updateNode(r.GetNode(fn.UUID), fn)
+		report.UpdatedNodes = append(report.UpdatedNodes, fn.UUID)
+	}
+	for _, node := range r.GetNodes() {
+		r.registerChildNode(node)
+	}
+
+	// connections are checked against the live nodes so the inputs of replaced nodes are wired again
+	for _, connection := range flow.Connections {
+		target := r.GetNode(connection.TargetUUID)
+		if target == nil || target.HasConnection(connection) {
+			continue
+		}
+		if err := r.AddConnection(connection); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return report, fmt.Errorf("Deploy() %w", errors.Join(errs...))
+	}
+	return report, nil
+}
+
+// DeployJSON decodes the flow document and deploys it, see Deploy()
+func (r *Runtime) DeployJSON(data []byte) (*DeployReport, error) {
+	flow, err := DecodeFlow(data)
+	if err != nil {
+		return nil, fmt.Errorf("DeployJSON() %w", err)
+	}
+	return r.Deploy(flow)
+}
+
+func (r *Runtime) validateDeploy(flow *Flow) error {
+	if err := flow.validate(); err != nil {
+		return err
+	}
+	if r.registry == nil {
+		return errors.New("registry has not been added to the runtime")
+	}
+	uuids := make(map[string]bool, len(flow.Nodes))
+	for _, fn := range flow.Nodes {
+		if _, err := r.flowPrototype(fn); err != nil {
+			return err
+		}
+		uuids[fn.UUID] = true
+	}
+	for _, connection := range flow.Connections {
+		if !uuids[connection.SourceUUID] || !uuids[connection.TargetUUID] {
+			return fmt.Errorf("connection from %s to %s is to a node that is not in the flow", connection.SourceUUID, connection.TargetUUID)
+		}
+	}
+	return r.checkFlowCycles(flow)
+}
+
+// replaceNode recreates the node from the flow, it keeps its place in the node order and its saved values. The
+// connections to the node are deleted with it and the connections from the node are removed from their targets,
+// as their coercion was built from the ports of the old node. It returns the connections from the node so the
+// caller can add them again.
+func (r *Runtime) replaceNode(fn *FlowNode) ([]*Connection, error) {
+	node, err := r.newFlowNode(fn)
+	if err != nil {
+		return nil, err
+	}
+	var outgoing []*Connection
+	for _, connection := range r.GetConnections() {
+		if connection.SourceUUID == fn.UUID && connection.TargetUUID != fn.UUID {
+			outgoing = append(outgoing, connection.copy())
+		}
+	}
+	index := r.nodeIndex(fn.UUID)
+	if err := r.deleteNode(fn.UUID); err != nil {
+		return nil, err
+	}
+	for _, connection := range outgoing {
+		if target := r.GetNode(connection.TargetUUID); target != nil {
+			target.RemoveConnection(connection)
+		}
+	}
+	return outgoing, r.addNode(node, index)
+}
+
+// UpdateNodeSettings changes the settings of a node the same way as Deploy(); a node that allows HotFix() is
+// updated live, else it is recreated with the new settings and its connections to and from other nodes. It returns the node that has
+// the new settings.
+func (r *Runtime) UpdateNodeSettings(uuid string, settings *Settings) (Node, error) {
+	node := r.GetNode(uuid)
+	if node == nil {
+		return nil, fmt.Errorf("UpdateNodeSettings() node with uuid %s not found", uuid)
+	}
+	fn := flowNodeFromNode(node)
+	fn.Settings = settings
+	if jsonEqual(node.GetSettings(), settings) {
+		return node, nil
+	}
+	if node.HotFix() {
+		r.updateNode(node, fn)
+		return node, nil
+	}
+	if r.registry == nil {
+		return nil, errors.New("UpdateNodeSettings() registry has not been added to the runtime, the node does not allow a hot fix")
+	}
+	connections := append([]*Connection{}, node.GetConnections()...)
+	outgoing, err := r.replaceNode(fn)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateNodeSettings() %w", err)
+	}
+	replaced := r.GetNode(uuid)
+	for _, child := range r.GetNodes() {
+		if child.GetParentUUID() == uuid {
+			r.registerChildNode(child)
+		}
+	}
+	r.registerChildNode(replaced)
+	var errs []error
+	for _, connection := range append(connections, outgoing...) {
+		if err := r.AddConnection(connection.copy()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return replaced, fmt.Errorf("UpdateNodeSettings() %w", errors.Join(errs...))
+	}
+	return replaced, nil
+}
+
+// updateNode applies the changes of the flow node to the running node
+func (r *Runtime) updateNode(node Node, fn *FlowNode) {
+	if !jsonEqual(node.GetSettings(), fn.Settings) {
+		node.UpdateSettings(fn.Settings)
+	}
+	if node.GetNodeName() != fn.Name {
+		node.SetNodeName(fn.Name)
+	}
+	if !jsonEqual(node.GetMeta(), fn.Meta) {
+		parentUUID := node.GetParentUUID()
+		node.setMeta(&Options{Meta: fn.Meta})
+		if node.GetParentUUID() != parentUUID {
+			r.unregisterChildNode(node, parentUUID)
+		}
+	}
+	if fn.Details != nil {
+		node.SetDetails(fn.Details)
+	}
+}
+
+// containsConnection returns true if connections has the same connection, see Connection.same()
+func containsConnection(connections []*Connection, connection *Connection) bool {
+	for _, c := range connections {
+		if c.same(connection) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares two values by their JSON encoding so a value loaded from a flow document
+// is equal to the same value set in code
+func jsonEqual(a, b any) bool {
+	aj, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bj, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
@@ -1,23 +1,34 @@
 package reactive
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
+	"github.com/NubeIO/reactive/tracer"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Message struct {
-	Port     *Port
-	NodeUUID string
-	NodeID   string
+	Port     *Port           `json:"port"`
+	NodeUUID string          `json:"nodeUUID"`
+	NodeID   string          `json:"nodeID"`
+	Topic    string          `json:"topic,omitempty"` // set by the EventBus to the topic the message was published on
+	Trace    *tracer.Message `json:"trace,omitempty"` // set on a message of the tracer of a node, see TracerTopic()
+	written  bool            // set by Runtime.WritePort(), the write has already set the last value of the input
 }
 
 // EventBus manages event subscriptions and publishes events.
 type EventBus struct {
-	mu          sync.Mutex
-	handlers    map[string][]chan *Message
-	subscribers map[chan *Message]string
-	WS          *WSHub
+	mu        sync.RWMutex
+	handlers  *topicTree
+	inFlight  atomic.Int64  // messages buffered but not yet delivered to a subscriber
+	rejected  atomic.Uint64 // messages dropped as their topic was not valid
+	closed    chan struct{}
+	closeOnce sync.Once
+	WS        *WSHub
 }
 
 // NewEventBus creates a new EventBus.
@@ -25,108 +36,119 @@ func NewEventBus() *EventBus {
 	ws := NewWSHub()
 	go ws.Run()
 	return &EventBus{
-		handlers:    make(map[string][]chan *Message),
-		subscribers: make(map[chan *Message]string),
-		WS:          ws,
+		handlers: newTopicTree(),
+		closed:   make(chan struct{}),
+		WS:       ws,
 	}
 }
 
-func (eb *EventBus) Subscribe(topic string, ch chan *Message) {
-	eb.mu.Lock()
-	defer eb.mu.Unlock()
-	eb.handlers[topic] = append(eb.handlers[topic], ch)
-	eb.subscribers[ch] = topic
+// Subscribe calls handler for every message published on a topic that matches the pattern, the topic can
+// use the + and # wildcards see TopicMatch(). opts sets the delivery policy and buffer size.
+func (eb *EventBus) Subscribe(topic string, handler func(*Message), opts ...*SubscribeOptions) (*Subscription, error) {
+	if handler == nil {
+		return nil, errors.New("Subscribe() handler can not be empty")
+	}
+	return eb.subscribe(topic, handler, nil, opts)
 }
 
-// Unsubscribe unsubscribes a channel from a topic.
-func (eb *EventBus) Unsubscribe(topic string, ch chan *Message) {
-	eb.mu.Lock()
-	defer eb.mu.Unlock()
-
-	// Remove the channel from the list of subscribers for the topic
-	subscribers := eb.handlers[topic]
-	for i, sub := range subscribers {
-		if sub == ch {
-			close(sub)                 // Close the channel to stop the goroutine
-			subscribers[i] = nil       // Set the channel to nil
-			delete(eb.subscribers, ch) // Remove the subscriber entry
-			break
-		}
+// SubscribeChannel sends every message published on a topic that matches the pattern to ch, the bus never
+// closes ch as it is owned by the caller.
+func (eb *EventBus) SubscribeChannel(topic string, ch chan *Message, opts ...*SubscribeOptions) (*Subscription, error) {
+	if ch == nil {
+		return nil, errors.New("SubscribeChannel() channel can not be empty")
 	}
-	eb.handlers[topic] = subscribers // Update the subscribers list for the topic
-	fmt.Printf("Unsubscribed from topic: %s\n", topic)
+	return eb.subscribe(topic, nil, ch, opts)
 }
 
-// Publish publishes an event to all subscribers of a topic.
-func (eb *EventBus) Publish(topic string, data *Message) {
+func (eb *EventBus) subscribe(topic string, handler func(*Message), ch chan *Message, opts []*SubscribeOptions) (*Subscription, error) {
+	if err := ValidateTopicPattern(topic); err != nil {
+		return nil, fmt.Errorf("Subscribe() %w", err)
+	}
+	var opt *SubscribeOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	sub := newSubscriber(eb, topic, handler, ch, opt)
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
-	for _, ch := range eb.handlers[topic] {
-		go func(ch chan *Message) {
-			ch <- data
-		}(ch)
-	}
+	eb.handlers.add(topic, sub)
+	return &Subscription{bus: eb, sub: sub}, nil
 }
 
-type WSConnection struct {
-	Conn *websocket.Conn
-	Send chan *Message // Channel to Send messages
-
+// unsubscribe removes the subscriber from the topic tree and stops its delivery
+func (eb *EventBus) unsubscribe(sub *subscriber) {
+	eb.mu.Lock()
+	eb.handlers.remove(strings.Split(sub.topic, TopicSeparator), sub)
+	eb.mu.Unlock()
+	sub.close()
 }
 
-type WSHub struct {
-	Connections map[*WSConnection]bool
-	Register    chan *WSConnection
-	Unregister  chan *WSConnection
-	Broadcast   chan *Message
-	mu          sync.Mutex // Mutex to protect concurrent access to connections
-
+// Publish buffers the message for all subscribers of the patterns that match the topic, messages to
+// a subscriber are delivered in the order they were published. A message on a topic that is not valid is dropped
+// and counted, see Rejected().
+func (eb *EventBus) Publish(topic string, data *Message) {
+	if err := ValidateTopic(topic); err != nil {
+		eb.rejected.Add(1)
+		return
+	}
+	eb.mu.RLock()
+	subscribers := eb.handlers.matchTopic(topic, nil)
+	eb.mu.RUnlock()
+	if len(subscribers) == 0 || data == nil {
+		return
+	}
+	m := *data
+	m.Topic = topic
+	for _, sub := range subscribers {
+		sub.enqueue(topic, &m)
+	}
 }
 
-func NewWSConnection(conn *websocket.Conn) *WSConnection {
-	fmt.Printf("ADD NEW WS Conntection :s", conn.LocalAddr().String())
-	return &WSConnection{
-		Conn: conn,
-		Send: make(chan *Message),
+// GetSubscriptionStats returns the delivery counters of every subscriber
+func (eb *EventBus) GetSubscriptionStats() []*SubscriptionStats {
+	eb.mu.RLock()
+	subscribers := eb.handlers.all(nil)
+	eb.mu.RUnlock()
+	out := make([]*SubscriptionStats, 0, len(subscribers))
+	for _, sub := range subscribers {
+		out = append(out, sub.stats())
 	}
+	return out
 }
 
-func (h *WSHub) Unsubscribe(conn *WSConnection) {
-	h.mu.Lock() // Use a mutex to handle concurrent access
-	defer h.mu.Unlock()
-
-	if _, ok := h.Connections[conn]; ok {
-		delete(h.Connections, conn)
-		close(conn.Send)
-	}
+// InFlight returns the count of messages that have been published but not yet delivered
+func (eb *EventBus) InFlight() int64 {
+	return eb.inFlight.Load()
 }
 
-func NewWSHub() *WSHub {
-	return &WSHub{
-		Connections: make(map[*WSConnection]bool),
-		Register:    make(chan *WSConnection),
-		Unregister:  make(chan *WSConnection),
-		Broadcast:   make(chan *Message),
-	}
+// Rejected returns the count of messages that were not published as their topic was not valid
+func (eb *EventBus) Rejected() uint64 {
+	return eb.rejected.Load()
 }
 
-func (h *WSHub) Run() {
-	for {
+// Wait blocks until all in-flight messages are delivered or ctx is done
+func (eb *EventBus) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for eb.InFlight() > 0 {
 		select {
-		case conn := <-h.Register:
-			h.mu.Lock()
-			h.Connections[conn] = true
-			h.mu.Unlock()
-		case msg := <-h.Broadcast:
-			h.mu.Lock()
-			for conn := range h.Connections {
-				select {
-				case conn.Send <- msg:
-				default:
-					h.Unsubscribe(conn)
-				}
-			}
-			h.mu.Unlock()
+		case <-ctx.Done():
+			return fmt.Errorf("Wait() %d messages still in flight: %w", eb.InFlight(), ctx.Err())
+		case <-ticker.C:
 		}
 	}
+	return nil
+}
+
+// Close drops any messages still waiting to be delivered, the bus must not be used after it is closed
+func (eb *EventBus) Close() {
+	eb.closeOnce.Do(func() {
+		close(eb.closed)
+		eb.mu.RLock()
+		subscribers := eb.handlers.all(nil)
+		eb.mu.RUnlock()
+		for _, sub := range subscribers {
+			sub.close()
+		}
+	})
 }
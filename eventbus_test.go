@@ -0,0 +1,206 @@
+package reactive
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		match   bool
+	}{
+		{"math/add/a/out", "math/add/a/out", true},
+		{"math/add/a/out", "math/add/b/out", false},
+		{"+/+/a/out", "math/add/a/out", true},
+		{"+/+/a/+", "math/add/a/in", true},
+		{"+/+/a/+", "math/add/a", false},
+		{"math/#", "math/add/a/out", true},
+		{"math/#", "math", true},
+		{"#", "math/add/a/out", true},
+		{"bacnet/#", "math/add/a/out", false},
+		{"+/+/+/+", "$path/folder/point/out", false},
+		{"#", "$path/folder/point/out", false},
+		{"$path/#", "$path/folder/point/out", true},
+	}
+	for _, test := range tests {
+		if got := TopicMatch(test.pattern, test.topic); got != test.match {
+			t.Errorf("TopicMatch(%s, %s) = %v", test.pattern, test.topic, got)
+		}
+	}
+	if ValidateTopicPattern("math/#/out") == nil || ValidateTopicPattern("math/a+") == nil {
+		t.Error("expected invalid patterns")
+	}
+}
+
+func receive(t *testing.T, ch chan *Message) *Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return nil
+}
+
+func TestEventBusWildcards(t *testing.T) {
+	bus := NewEventBus()
+	byNode := make(chan *Message, 10)
+	byPlugin := make(chan *Message, 10)
+	subscription, err := bus.SubscribeChannel(NodeTopic("a"), byNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.SubscribeChannel(PluginTopic("test-plugin"), byPlugin); err != nil {
+		t.Fatal(err)
+	}
+	bus.Publish(PortTopic("test-plugin", "add", "a", "out"), &Message{NodeUUID: "a"})
+	bus.Publish(PortTopic("test-plugin", "add", "b", "out"), &Message{NodeUUID: "b"})
+	if msg := receive(t, byNode); msg.NodeUUID != "a" {
+		t.Fatalf("unexpected message from %s", msg.NodeUUID)
+	}
+	receive(t, byPlugin)
+	receive(t, byPlugin)
+
+	subscription.Unsubscribe()
+	bus.Publish(PortTopic("test-plugin", "add", "a", "out"), &Message{NodeUUID: "a"})
+	receive(t, byPlugin)
+	select {
+	case <-byNode:
+		t.Fatal("unsubscribed channel should not get messages")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestInvalidTopics(t *testing.T) {
+	runtime := NewRuntime(nil)
+	for _, uuid := range []string{"a/b", "a+", "#"} {
+		if err := runtime.AddNode(newTestNode(uuid, "add", runtime.GetEventBus())); err == nil {
+			t.Fatalf("expected an error for the node uuid %s", uuid)
+		}
+	}
+	if runtime.NodeCount() != 0 {
+		t.Fatal("no nodes should be added")
+	}
+	runtime.GetEventBus().Publish(PortTopic("test-plugin", "add", "a+", "out"), &Message{NodeUUID: "a+"})
+	if rejected := runtime.GetEventBus().Rejected(); rejected != 1 {
+		t.Fatalf("expected 1 rejected message got %d", rejected)
+	}
+}
+
+func TestPublishOnTopic(t *testing.T) {
+	runtime := NewRuntime(nil)
+	folder := newTestNode("folder", "folder", runtime.GetEventBus())
+	folder.SetNodeName("myFolder")
+	point := NewBaseNode(NodeInfo("point", "point", "bacnetPoint", "bacnet"), runtime.GetEventBus(), &Options{Meta: &Meta{ParentUUID: "folder"}})
+	point.NewOutputPort("out", "out", portTypeFloat)
+	point.PublishOnTopic = true
+	runtime.AddNode(folder)
+	runtime.AddNode(point)
+
+	ch := make(chan *Message, 1)
+	runtime.GetEventBus().SubscribeChannel("$path/myFolder/bacnetPoint/out", ch)
+	// the path topic is not in the namespace of the plugins
+	plugins := make(chan *Message, 2)
+	runtime.GetEventBus().SubscribeChannel("+/+/+/+", plugins)
+	point.PublishMessage(&Port{ID: "out", Name: "out", Value: 1.0})
+	if msg := receive(t, ch); msg.NodeUUID != "point" {
+		t.Fatalf("unexpected message from %s", msg.NodeUUID)
+	}
+	if msg := receive(t, plugins); msg.Topic != "bacnet/point/point/out" {
+		t.Fatalf("unexpected topic %s", msg.Topic)
+	}
+	select {
+	case msg := <-plugins:
+		t.Fatalf("expected no message on the path topic for the plugin pattern, got %s", msg.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeliveryOrder(t *testing.T) {
+	bus := NewEventBus()
+	ch := make(chan *Message)
+	bus.SubscribeChannel("a/#", ch, &SubscribeOptions{Policy: DeliveryBlock, BufferSize: 4})
+	go func() {
+		for i := 0; i < 100; i++ {
+			bus.Publish("a/b", &Message{NodeID: fmt.Sprint(i)})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if msg := receive(t, ch); msg.NodeID != fmt.Sprint(i) {
+			t.Fatalf("expected message %d got %s", i, msg.NodeID)
+		}
+	}
+}
+
+func TestDeliveryPolicies(t *testing.T) {
+	tests := []struct {
+		policy    DeliveryPolicy
+		topics    []string
+		want      []string
+		dropped   uint64
+		coalesced uint64
+	}{
+		{DeliveryDropOldest, []string{"a", "a", "a", "a"}, []string{"2", "3"}, 2, 0},
+		{DeliveryDropNewest, []string{"a", "a", "a", "a"}, []string{"0", "1"}, 2, 0},
+		{DeliveryLatest, []string{"a", "b", "a", "b"}, []string{"2", "3"}, 0, 2},
+	}
+	for _, test := range tests {
+		bus := NewEventBus()
+		ch := make(chan *Message)
+		bus.SubscribeChannel("#", ch, &SubscribeOptions{Policy: test.policy, BufferSize: 2})
+		// the first message is taken from the buffer and blocks the subscriber until it is read
+		bus.Publish("first", &Message{NodeID: "first"})
+		for bus.InFlight() != 1 || bus.GetSubscriptionStats()[0].Buffered != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for i, topic := range test.topics {
+			bus.Publish(topic, &Message{NodeID: fmt.Sprint(i)})
+		}
+		stats := bus.GetSubscriptionStats()[0]
+		if stats.Dropped != test.dropped || stats.Coalesced != test.coalesced {
+			t.Fatalf("%s: unexpected stats %+v", test.policy, stats)
+		}
+		receive(t, ch)
+		for _, want := range test.want {
+			if msg := receive(t, ch); msg.NodeID != want {
+				t.Fatalf("%s: expected message %s got %s", test.policy, want, msg.NodeID)
+			}
+		}
+		bus.Close()
+	}
+}
+
+func TestSubscriptionHandler(t *testing.T) {
+	bus := NewEventBus()
+	received := make(chan *Message, 1)
+	subscription, err := bus.Subscribe("a/+", func(msg *Message) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.Topic() != "a/+" {
+		t.Fatalf("unexpected topic %s", subscription.Topic())
+	}
+	bus.Publish("a/b", &Message{NodeID: "b"})
+	receive(t, received)
+	if err := bus.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := subscription.Stats(); stats.Delivered != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	subscription.Unsubscribe()
+	subscription.Unsubscribe()
+	if len(bus.GetSubscriptionStats()) != 0 || len(bus.handlers.children) != 0 {
+		t.Fatal("the subscription should be removed from the bus")
+	}
+	if _, err := bus.Subscribe("a/#/b", func(*Message) {}); err == nil {
+		t.Fatal("expected an error for an invalid topic")
+	}
+}
@@ -0,0 +1,236 @@
+package reactive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// FlowVersion is the version of the flow document written by ExportFlow()
+const FlowVersion = 1
+
+// Flow is the document of a whole flow, it can be saved by the editor and loaded back into a runtime
+type Flow struct {
+	Version     int           `json:"version"`
+	Nodes       []*FlowNode   `json:"nodes"`
+	Connections []*Connection `json:"connections"`
+}
+
+// FlowNode is a node in a flow document, the node is created from the registry by its plugin name and id
+type FlowNode struct {
+	UUID        string    `json:"uuid"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	PluginName  string    `json:"pluginName"`
+	Application string    `json:"application,omitempty"`
+	Settings    *Settings `json:"settings,omitempty"`
+	Meta        *Meta     `json:"meta,omitempty"`
+	Details     *Details  `json:"details,omitempty"`
+}
+
+// DecodeFlow decodes a flow document from JSON
+func DecodeFlow(data []byte) (*Flow, error) {
+	flow := &Flow{}
+	if err := json.Unmarshal(data, flow); err != nil {
+		return nil, fmt.Errorf("failed to decode flow: %w", err)
+	}
+	return flow, nil
+}
+
+// Encode encodes the flow document as JSON
+func (f *Flow) Encode() ([]byte, error) {
+	return json.Marshal(f)
+}
+
+func (f *Flow) validate() error {
+	if f == nil {
+		return errors.New("flow can not be empty")
+	}
+	if f.Version != FlowVersion {
+		return fmt.Errorf("flow version %d is not supported, expected version %d", f.Version, FlowVersion)
+	}
+	uuids := make(map[string]bool, len(f.Nodes))
+	for _, fn := range f.Nodes {
+		if fn == nil || fn.UUID == "" {
+			return errors.New("flow node uuid can not be empty")
+		}
+		if uuids[fn.UUID] {
+			return fmt.Errorf("flow node uuid %s is used more than once", fn.UUID)
+		}
+		uuids[fn.UUID] = true
+	}
+	for _, connection := range f.Connections {
+		if connection == nil {
+			return errors.New("flow connection can not be empty")
+		}
+	}
+	return nil
+}
+
+// ---------------------------- RUNTIME FLOW -------------------------- //
+
+func (r *Runtime) AddRegistry(registry *Registry) {
+	r.registry = registry
+}
+
+func (r *Runtime) GetRegistry() *Registry {
+	return r.registry
+}
+
+// LoadFlow creates the nodes of the flow from the registry, applies their settings, meta and details and
+// wires the connections. If anything fails the nodes that were added are removed again.
+func (r *Runtime) LoadFlow(flow *Flow) error {
+	if err := flow.validate(); err != nil {
+		return fmt.Errorf("LoadFlow() %w", err)
+	}
+	if r.registry == nil {
+		return errors.New("LoadFlow() registry has not been added to the runtime")
+	}
+	for _, fn := range flow.Nodes {
+		if r.GetNode(fn.UUID) != nil {
+			return fmt.Errorf("LoadFlow() node with uuid %s already exists", fn.UUID)
+		}
+		if _, err := r.flowPrototype(fn); err != nil {
+			return fmt.Errorf("LoadFlow() %w", err)
+		}
+	}
+	if err := r.checkFlowCycles(flow); err != nil {
+		return fmt.Errorf("LoadFlow() %w", err)
+	}
+
+	var added []string
+	rollback := func(err error) error {
+		for i := len(added) - 1; i >= 0; i-- {
+			r.RemoveNode(added[i])
+		}
+		return fmt.Errorf("LoadFlow() %w", err)
+	}
+	for _, fn := range flow.Nodes {
+		node, err := r.newFlowNode(fn)
+		if err != nil {
+			return rollback(err)
+		}
+		if err := r.AddNode(node); err != nil {
+			return rollback(err)
+		}
+		added = append(added, fn.UUID)
+	}
+	for _, fn := range flow.Nodes {
+		r.registerChildNode(r.GetNode(fn.UUID))
+	}
+	for _, connection := range flow.Connections {
+		if err := r.AddConnection(connection); err != nil {
+			return rollback(err)
+		}
+	}
+	return nil
+}
+
+// LoadFlowJSON decodes the flow document and loads it, see LoadFlow()
+func (r *Runtime) LoadFlowJSON(data []byte) error {
+	flow, err := DecodeFlow(data)
+	if err != nil {
+		return fmt.Errorf("LoadFlowJSON() %w", err)
+	}
+	return r.LoadFlow(flow)
+}
+
+// ExportFlow returns the flow document of the runtime, nodes are in the order they were added and
+// connections are grouped by their target node.
+func (r *Runtime) ExportFlow() *Flow {
+	flow := &Flow{
+		Version:     FlowVersion,
+		Nodes:       []*FlowNode{},
+		Connections: []*Connection{},
+	}
+	for _, node := range r.GetNodes() {
+		flow.Nodes = append(flow.Nodes, flowNodeFromNode(node))
+	}
+	for _, connection := range r.GetConnections() {
+		flow.Connections = append(flow.Connections, connection.copy())
+	}
+	return flow
+}
+
+// ExportFlowJSON returns the flow document of the runtime as JSON, see ExportFlow()
+func (r *Runtime) ExportFlowJSON() ([]byte, error) {
+	return r.ExportFlow().Encode()
+}
+
+// flowPrototype returns the prototype of the flow node from the registry, if the flow node sets its application
+// it must be the application of the prototype
+func (r *Runtime) flowPrototype(fn *FlowNode) (Node, error) {
+	prototype, err := r.registry.Get(fn.PluginName, fn.ID)
+	if err != nil {
+		return nil, err
+	}
+	if fn.Application != "" && fn.Application != prototype.GetApplicationUse() {
+		return nil, fmt.Errorf("node %s is for application %s, the flow node %s is for application %s",
+			registryKey(fn.PluginName, fn.ID), prototype.GetApplicationUse(), fn.UUID, fn.Application)
+	}
+	return prototype, nil
+}
+
+// newFlowNode creates a node from its prototype in the registry
+func (r *Runtime) newFlowNode(fn *FlowNode) (Node, error) {
+	prototype, err := r.flowPrototype(fn)
+	if err != nil {
+		return nil, err
+	}
+	opts := &Options{Meta: fn.Meta}
+	node := prototype.New(fn.UUID, fn.Name, r.eventBus, fn.Settings, opts)
+	if node == nil || node == prototype {
+		return nil, fmt.Errorf("node %s did not return a new instance from New()", registryKey(fn.PluginName, fn.ID))
+	}
+	if node.GetUUID() != fn.UUID {
+		return nil, fmt.Errorf("node %s did not use the uuid %s passed to New()", registryKey(fn.PluginName, fn.ID), fn.UUID)
+	}
+	if fn.Settings != nil {
+		node.AddSettings(fn.Settings)
+	}
+	if fn.Meta != nil && node.GetMeta() == nil {
+		node.setMeta(opts)
+	}
+	if fn.Details != nil {
+		node.SetDetails(fn.Details)
+	}
+	return node, nil
+}
+
+// registerChildNode registers the node as a child of the parent set in its meta
+func (r *Runtime) registerChildNode(node Node) {
+	if node == nil || node.GetParentUUID() == "" {
+		return
+	}
+	parent := r.GetNode(node.GetParentUUID())
+	if parent == nil {
+		return
+	}
+	parent.RegisterChildNode(node)
+}
+
+// unregisterChildNode removes the node from the children of the parent, a parent that has another instance
+// registered with the same uuid eg; a node that has been replaced keeps it
+func (r *Runtime) unregisterChildNode(node Node, parentUUID string) {
+	if node == nil || parentUUID == "" {
+		return
+	}
+	parent := r.GetNode(parentUUID)
+	if parent == nil || parent.GetChildNode(node.GetUUID()) != node {
+		return
+	}
+	parent.UnregisterChildNode(node.GetUUID())
+}
+
+func flowNodeFromNode(node Node) *FlowNode {
+	return &FlowNode{
+		UUID:        node.GetUUID(),
+		ID:          node.GetID(),
+		Name:        node.GetNodeName(),
+		PluginName:  node.GetPluginName(),
+		Application: node.GetApplicationUse(),
+		Settings:    node.GetSettings(),
+		Meta:        node.GetMeta(),
+		Details:     node.GetDetails(),
+	}
+}
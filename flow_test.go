@@ -0,0 +1,218 @@
+package reactive
+
+import (
+	"testing"
+	"time"
+)
+
+const testFlowJSON = `{"version":1,"nodes":[{"uuid":"a","id":"add","name":"add 1","pluginName":"test-plugin","settings":{"value":2},"meta":{"position":{"positionY":10,"positionX":20},"parentUUID":""}},{"uuid":"b","id":"sub","name":"sub 1","pluginName":"test-plugin","meta":{"position":{"positionY":30,"positionX":40},"parentUUID":"a"},"details":{"category":"math","parentID":null,"hasDB":false,"hasLogger":false}}],"connections":[{"source":"a","sourceHandle":"out","target":"b","targetHandle":"in","flowDirection":"subscriber"}]}`
+
+func newTestRegistry(t *testing.T) *Registry {
+	registry := NewRegistry()
+	for _, nodeID := range []string{"add", "sub"} {
+		if err := registry.Register(newTestNode("", nodeID, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+func TestFlowRoundTrip(t *testing.T) {
+	runtime := NewRuntime(nil)
+	runtime.AddRegistry(newTestRegistry(t))
+	if err := runtime.LoadFlowJSON([]byte(testFlowJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.NodeCount() != 2 || len(runtime.GetConnections()) != 1 {
+		t.Fatal("flow was not loaded")
+	}
+	if runtime.GetNode("a").GetChildNode("b") == nil {
+		t.Fatal("child node was not registered with its parent")
+	}
+	out, err := runtime.ExportFlowJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != testFlowJSON {
+		t.Fatalf("flow did not round trip\nwant: %s\ngot:  %s", testFlowJSON, out)
+	}
+}
+
+func TestLoadFlowUnknownNode(t *testing.T) {
+	runtime := NewRuntime(nil)
+	runtime.AddRegistry(NewRegistry())
+	if err := runtime.LoadFlowJSON([]byte(testFlowJSON)); err == nil {
+		t.Fatal("expected an error for nodes that are not registered")
+	}
+	if runtime.NodeCount() != 0 {
+		t.Fatal("no nodes should be added when the flow fails to load")
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	runtime := NewRuntime(nil)
+	runtime.AddRegistry(newTestRegistry(t))
+	if err := runtime.LoadFlowJSON([]byte(testFlowJSON)); err != nil {
+		t.Fatal(err)
+	}
+	hotFix := runtime.GetNode("b")
+	hotFix.SetHotFix()
+
+	flow := runtime.ExportFlow()
+	flow.Nodes[0].Settings = &Settings{Value: 3.0} // a does not allow hot fix so it is replaced
+	flow.Nodes[1].Settings = &Settings{Value: 1.0} // b allows hot fix
+	flow.Nodes = append(flow.Nodes, &FlowNode{UUID: "c", ID: "add", Name: "add 2", PluginName: "test-plugin"})
+	flow.Connections = []*Connection{
+		{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"},
+		{SourceUUID: "b", SourcePort: "out", TargetUUID: "c", TargetPort: "in"},
+	}
+	report, err := runtime.Deploy(flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.AddedNodes) != 1 || len(report.ReplacedNodes) != 1 || len(report.UpdatedNodes) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.AddedConnections) != 1 || len(report.RemovedConnections) != 0 {
+		t.Fatalf("unexpected connections in report %+v", report)
+	}
+	if runtime.GetNode("b") != hotFix || hotFix.GetSettings().GetFloat64Value() != 1 {
+		t.Fatal("hot fix node should be updated live")
+	}
+	if runtime.GetNodes()[0].GetUUID() != "a" || runtime.GetNode("a").GetSettings().GetFloat64Value() != 3 {
+		t.Fatal("replaced node should keep its place and have the new settings")
+	}
+	if len(runtime.GetConnections()) != 2 {
+		t.Fatalf("expected 2 connections got %d", len(runtime.GetConnections()))
+	}
+
+	report, err = runtime.Deploy(runtime.ExportFlow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HasChanges() || report.UnchangedNodes != 3 {
+		t.Fatalf("deploying the same flow should not change anything %+v", report)
+	}
+
+	flow = runtime.ExportFlow()
+	flow.Nodes = flow.Nodes[:2]
+	flow.Connections = flow.Connections[:1]
+	report, err = runtime.Deploy(flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.RemovedNodes) != 1 || len(report.RemovedConnections) != 1 || runtime.NodeCount() != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestDeployConnectionChanges(t *testing.T) {
+	runtime := NewRuntime(nil)
+	runtime.AddRegistry(newTestRegistry(t))
+	if err := runtime.LoadFlowJSON([]byte(testFlowJSON)); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GetNode("b").SetHotFix()
+
+	// a connection that only changes its delivery is added again
+	flow := runtime.ExportFlow()
+	flow.Connections[0].Delivery = &SubscribeOptions{Policy: DeliveryLatest}
+	report, err := runtime.Deploy(flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.AddedConnections) != 1 || len(report.RemovedConnections) != 1 {
+		t.Fatalf("expected the changed connection to be added again %+v", report)
+	}
+	connections := append([]*Connection{}, runtime.GetNode("b").GetConnections()...)
+	if len(connections) != 1 || connections[0].Delivery == nil || connections[0].Delivery.Policy != DeliveryLatest {
+		t.Fatalf("expected the connection to have the new delivery %+v", connections)
+	}
+	if report, err = runtime.Deploy(runtime.ExportFlow()); err != nil || report.HasChanges() {
+		t.Fatalf("deploying the same flow should not change anything %+v %v", report, err)
+	}
+
+	// the connections from a replaced node are built again from the new node
+	flow = runtime.ExportFlow()
+	flow.Nodes[0].Settings = &Settings{Value: 3.0}
+	if _, err := runtime.Deploy(flow); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := runtime.GetNode("b").GetConnections()
+	if len(rebuilt) != 1 || rebuilt[0] == connections[0] || rebuilt[0].Delivery.Policy != DeliveryLatest {
+		t.Fatalf("expected the connection from the replaced node to be added again %+v", rebuilt)
+	}
+	runtime.GetNode("a").(*testNode).PublishMessage(&Port{ID: "out", Name: "out", Value: 1.0})
+	select {
+	case msg := <-runtime.GetNode("b").(*testNode).Bus["in"]:
+		if msg.Port.Value != 1.0 {
+			t.Fatalf("unexpected value %v", msg.Port.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the value of the replaced node")
+	}
+}
+
+func TestLoadFlowApplication(t *testing.T) {
+	runtime := NewRuntime(nil)
+	registry := NewRegistry()
+	prototype := &testNode{BaseNode: NewBaseNode(&Info{NodeID: "add", Name: "add", PluginName: "test-plugin", Application: "modbus-driver"}, nil, nil)}
+	if err := registry.Register(prototype); err != nil {
+		t.Fatal(err)
+	}
+	runtime.AddRegistry(registry)
+
+	flow := &Flow{Version: FlowVersion, Nodes: []*FlowNode{{UUID: "a", ID: "add", PluginName: "test-plugin", Application: "bacnet-driver"}}}
+	if err := runtime.LoadFlow(flow); err == nil {
+		t.Fatal("expected an error for a node of another application")
+	}
+	if runtime.NodeCount() != 0 {
+		t.Fatal("no nodes should be added when the flow fails to load")
+	}
+	flow.Nodes[0].Application = "modbus-driver"
+	if err := runtime.LoadFlow(flow); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeployChildNodes(t *testing.T) {
+	runtime := NewRuntime(nil)
+	runtime.AddRegistry(newTestRegistry(t))
+	if err := runtime.LoadFlowJSON([]byte(testFlowJSON)); err != nil {
+		t.Fatal(err)
+	}
+	child := runtime.GetNode("b")
+
+	// b moves from a to c
+	flow := runtime.ExportFlow()
+	flow.Nodes = append(flow.Nodes, &FlowNode{UUID: "c", ID: "add", Name: "add 2", PluginName: "test-plugin"})
+	flow.Nodes[1].Meta = &Meta{ParentUUID: "c"}
+	if _, err := runtime.Deploy(flow); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GetNode("a").GetChildNode("b") != nil || runtime.GetNode("c").GetChildNode("b") != child {
+		t.Fatal("child node should move to its new parent")
+	}
+
+	// a replaced b is registered again, and the old instance is not kept
+	flow = runtime.ExportFlow()
+	flow.Nodes[1].Settings = &Settings{Value: 1.0}
+	if _, err := runtime.Deploy(flow); err != nil {
+		t.Fatal(err)
+	}
+	replaced := runtime.GetNode("b")
+	if replaced == child || runtime.GetNode("c").GetChildNode("b") != replaced {
+		t.Fatal("parent should have the replaced child node")
+	}
+
+	// a removed b is unregistered
+	flow = runtime.ExportFlow()
+	flow.Nodes = []*FlowNode{flow.Nodes[0], flow.Nodes[2]}
+	flow.Connections = nil
+	if _, err := runtime.Deploy(flow); err != nil {
+		t.Fatal(err)
+	}
+	if len(runtime.GetNode("c").GetChildNodes()) != 0 {
+		t.Fatal("removed child node should be unregistered from its parent")
+	}
+}
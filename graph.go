@@ -0,0 +1,241 @@
+package reactive
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ---------------------------- GRAPH -------------------------- //
+// the nodes and connections of a runtime are a directed graph, each connection is an edge from the source node
+// to the target node. The edges out of a CycleBreaker() node are left out, so a feedback loop is only allowed if
+// it goes through a delay or latch node.
+
+// ErrCycle is returned when the connections make a feedback loop that is not broken by a CycleBreaker() node
+var ErrCycle = errors.New("connections make a cycle")
+
+// Graph is the dependency graph of the nodes, a node depends on the nodes that are connected to its inputs
+type Graph struct {
+	nodes []string // node uuids in the order they were added
+	index map[string]int
+	edges map[string][]string // source uuid to the uuids of the nodes it feeds
+}
+
+func newGraph(nodes []string) *Graph {
+	g := &Graph{
+		nodes: nodes,
+		index: make(map[string]int, len(nodes)),
+		edges: make(map[string][]string),
+	}
+	for i, uuid := range nodes {
+		g.index[uuid] = i
+	}
+	return g
+}
+
+// addEdge adds an edge between two nodes of the graph, an edge is only added once
+func (g *Graph) addEdge(source, target string) {
+	if _, ok := g.index[source]; !ok {
+		return
+	}
+	if _, ok := g.index[target]; !ok {
+		return
+	}
+	for _, existing := range g.edges[source] {
+		if existing == target {
+			return
+		}
+	}
+	g.edges[source] = append(g.edges[source], target)
+}
+
+// Nodes returns the uuids of the nodes in the order they were added
+func (g *Graph) Nodes() []string {
+	return append([]string{}, g.nodes...)
+}
+
+// Dependents returns the uuids of the nodes the node feeds
+func (g *Graph) Dependents(uuid string) []string {
+	return append([]string{}, g.edges[uuid]...)
+}
+
+// reachable returns true if there is a path from one node to the other
+func (g *Graph) reachable(from, to string) bool {
+	visited := map[string]bool{}
+	stack := []string{from}
+	for len(stack) > 0 {
+		uuid := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if uuid == to {
+			return true
+		}
+		if visited[uuid] {
+			continue
+		}
+		visited[uuid] = true
+		stack = append(stack, g.edges[uuid]...)
+	}
+	return false
+}
+
+// Cycles returns the groups of nodes that feed each other, the nodes of each cycle are in the order they were added
+func (g *Graph) Cycles() [][]string {
+	var (
+		cycles  [][]string
+		stack   []string
+		next    int
+		index   = map[string]int{}
+		low     = map[string]int{}
+		onStack = map[string]bool{}
+		visit   func(uuid string)
+	)
+	// tarjan's strongly connected components, a component of more than one node or a node that feeds itself is a cycle
+	visit = func(uuid string) {
+		index[uuid], low[uuid] = next, next
+		next++
+		stack = append(stack, uuid)
+		onStack[uuid] = true
+		for _, target := range g.edges[uuid] {
+			if _, visited := index[target]; !visited {
+				visit(target)
+				low[uuid] = min(low[uuid], low[target])
+			} else if onStack[target] {
+				low[uuid] = min(low[uuid], index[target])
+			}
+		}
+		if low[uuid] != index[uuid] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == uuid {
+				break
+			}
+		}
+		if len(component) > 1 || g.feedsItself(uuid) {
+			sort.Slice(component, func(i, j int) bool { return g.index[component[i]] < g.index[component[j]] })
+			cycles = append(cycles, component)
+		}
+	}
+	for _, uuid := range g.nodes {
+		if _, visited := index[uuid]; !visited {
+			visit(uuid)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return g.index[cycles[i][0]] < g.index[cycles[j][0]] })
+	return cycles
+}
+
+func (g *Graph) feedsItself(uuid string) bool {
+	for _, target := range g.edges[uuid] {
+		if target == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// TopologicalOrder returns the node uuids so each node comes after the nodes it depends on, nodes that do not
+// depend on each other stay in the order they were added. An error wrapping ErrCycle is returned if there is a cycle.
+func (g *Graph) TopologicalOrder() ([]string, error) {
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, cycleError(cycles[0])
+	}
+	inDegree := make(map[string]int, len(g.nodes))
+	for _, targets := range g.edges {
+		for _, target := range targets {
+			inDegree[target]++
+		}
+	}
+	var ready []int // indexes of the nodes with no dependencies left, sorted
+	for i, uuid := range g.nodes {
+		if inDegree[uuid] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	order := make([]string, 0, len(g.nodes))
+	for len(ready) > 0 {
+		uuid := g.nodes[ready[0]]
+		ready = ready[1:]
+		order = append(order, uuid)
+		for _, target := range g.edges[uuid] {
+			inDegree[target]--
+			if inDegree[target] == 0 {
+				i := g.index[target]
+				at := sort.SearchInts(ready, i)
+				ready = append(ready[:at], append([]int{i}, ready[at:]...)...)
+			}
+		}
+	}
+	return order, nil
+}
+
+func cycleError(cycle []string) error {
+	return fmt.Errorf("%w between nodes %s, add a delay or latch node to the loop", ErrCycle, strings.Join(cycle, ", "))
+}
+
+// ---------------------------- RUNTIME GRAPH -------------------------- //
+
+// Graph returns the dependency graph of the nodes in the runtime
+func (r *Runtime) Graph() *Graph {
+	nodes := r.GetNodes()
+	uuids := make([]string, 0, len(nodes))
+	breakers := map[string]bool{}
+	for _, node := range nodes {
+		uuids = append(uuids, node.GetUUID())
+		breakers[node.GetUUID()] = node.CycleBreaker()
+	}
+	g := newGraph(uuids)
+	for _, connection := range r.GetConnections() {
+		if !breakers[connection.SourceUUID] {
+			g.addEdge(connection.SourceUUID, connection.TargetUUID)
+		}
+	}
+	return g
+}
+
+// TopologicalOrder returns the uuids of the nodes in the runtime so each node comes after the nodes it depends on
+func (r *Runtime) TopologicalOrder() ([]string, error) {
+	return r.Graph().TopologicalOrder()
+}
+
+// checkCycle returns an error if adding the connection would make a cycle
+func (r *Runtime) checkCycle(connection *Connection) error {
+	source := r.GetNode(connection.SourceUUID)
+	if source == nil || source.CycleBreaker() {
+		return nil
+	}
+	g := r.Graph()
+	if connection.SourceUUID == connection.TargetUUID || g.reachable(connection.TargetUUID, connection.SourceUUID) {
+		return cycleError([]string{connection.SourceUUID, connection.TargetUUID})
+	}
+	return nil
+}
+
+// checkFlowCycles returns an error if the connections of the flow make a cycle, the nodes are looked up in the
+// registry to find the ones that break a cycle
+func (r *Runtime) checkFlowCycles(flow *Flow) error {
+	uuids := make([]string, 0, len(flow.Nodes))
+	breakers := map[string]bool{}
+	for _, fn := range flow.Nodes {
+		uuids = append(uuids, fn.UUID)
+		if prototype, err := r.registry.Get(fn.PluginName, fn.ID); err == nil {
+			breakers[fn.UUID] = prototype.CycleBreaker()
+		}
+	}
+	g := newGraph(uuids)
+	for _, connection := range flow.Connections {
+		if !breakers[connection.SourceUUID] {
+			g.addEdge(connection.SourceUUID, connection.TargetUUID)
+		}
+	}
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return cycleError(cycles[0])
+	}
+	return nil
+}
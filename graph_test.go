@@ -0,0 +1,63 @@
+package reactive
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGraphTopologicalOrder(t *testing.T) {
+	g := newGraph([]string{"a", "b", "c", "d"})
+	g.addEdge("c", "a")
+	g.addEdge("a", "b")
+	g.addEdge("d", "b")
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c", "a", "d", "b"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("expected %v, got %v", want, order)
+	}
+
+	g.addEdge("b", "c")
+	if cycles := g.Cycles(); len(cycles) != 1 || !reflect.DeepEqual(cycles[0], []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected cycles %v", cycles)
+	}
+	if _, err := g.TopologicalOrder(); !errors.Is(err, ErrCycle) {
+		t.Fatalf("expected ErrCycle, got %v", err)
+	}
+}
+
+func TestRuntimeRejectsCycles(t *testing.T) {
+	runtime := NewRuntime(nil)
+	a := newTestNode("a", "add", runtime.GetEventBus())
+	b := newTestNode("b", "add", runtime.GetEventBus())
+	latch := newTestNode("latch", "latch", runtime.GetEventBus())
+	latch.SetCycleBreaker()
+	for _, node := range []Node{a, b, latch} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "b", SourcePort: "out", TargetUUID: "a", TargetPort: "in"}); !errors.Is(err, ErrCycle) {
+		t.Fatalf("expected ErrCycle, got %v", err)
+	}
+
+	// the loop is allowed when it goes through the latch
+	if err := runtime.AddConnection(&Connection{SourceUUID: "b", SourcePort: "out", TargetUUID: "latch", TargetPort: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "latch", SourcePort: "out", TargetUUID: "a", TargetPort: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := runtime.TopologicalOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "latch"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("expected %v, got %v", want, order)
+	}
+}
@@ -8,6 +8,10 @@ func (n *BaseNode) GetNodeName() string {
 	return n.Name
 }
 
+func (n *BaseNode) SetNodeName(name string) {
+	n.Name = name
+}
+
 func (n *BaseNode) GetPluginName() string {
 	return n.pluginName
 }
@@ -8,3 +8,30 @@ func (n *BaseNode) AddLogger(logger *logrus.Logger) {
 func (n *BaseNode) GetLogger() *logrus.Logger {
 	return n.logger
 }
+
+// log returns the logger of the node, or else the logger of its runtime
+func (n *BaseNode) log() *logrus.Logger {
+	if n.logger != nil {
+		return n.logger
+	}
+	if n.runtime != nil {
+		return n.runtime.GetLogger()
+	}
+	return logrus.StandardLogger()
+}
+
+// AddLogger sets the logger of the runtime, the default is the standard logrus logger
+func (r *Runtime) AddLogger(logger *logrus.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger = logger
+}
+
+func (r *Runtime) GetLogger() *logrus.Logger {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.logger == nil {
+		return logrus.StandardLogger()
+	}
+	return r.logger
+}
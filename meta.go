@@ -13,6 +13,7 @@ type Meta struct {
 func (n *BaseNode) setMeta(opts *Options) {
 	if opts != nil {
 		n.meta = opts.Meta
+		n.parentUUID = ""
 		if n.meta != nil {
 			n.parentUUID = n.meta.ParentUUID
 		}
@@ -0,0 +1,212 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NubeIO/reactive"
+	"strings"
+	"sync"
+)
+
+// Outbound mirrors the EventBus topics that match Topic to the broker, the MQTT topic is Prefix/<bus topic>
+type Outbound struct {
+	Topic  string `json:"topic"` // EventBus topic, it can use wildcards eg; +/+/<nodeUUID>/+
+	Prefix string `json:"prefix"`
+	QoS    byte   `json:"qos"`
+	Retain bool   `json:"retain"` // keep the last value of each topic on the broker
+}
+
+// Inbound sends the MQTT messages that match Topic to a node input port. If NodeUUID or PortID is empty it
+// is taken from the MQTT topic, which must then end with <nodeUUID>/<portID>
+type Inbound struct {
+	Topic    string `json:"topic"` // MQTT topic, it can use wildcards
+	NodeUUID string `json:"nodeUUID,omitempty"`
+	PortID   string `json:"portID,omitempty"`
+	QoS      byte   `json:"qos"`
+}
+
+// writtenByPrefix is the start of the WrittenBy of the values the bridge writes to an input
+const writtenByPrefix = "mqtt:"
+
+// Config is the topic mapping of the bridge. A value the bridge writes to an input is not mirrored back to the
+// broker, nor is a message whose MQTT topic matches an inbound topic.
+type Config struct {
+	Outbound []*Outbound `json:"outbound"`
+	Inbound  []*Inbound  `json:"inbound"`
+}
+
+// Bridge mirrors EventBus messages to an MQTT broker and sends MQTT messages to node input ports.
+// The payload is the JSON of the reactive.Port, an inbound payload can also be a plain JSON value or text.
+type Bridge struct {
+	mu            sync.Mutex
+	runtime       *reactive.Runtime
+	client        Client
+	config        *Config
+	subscriptions []*reactive.Subscription
+	inbound       []string
+	errors        chan error
+}
+
+func New(runtime *reactive.Runtime, client Client, config *Config) *Bridge {
+	if config == nil {
+		config = &Config{}
+	}
+	return &Bridge{
+		runtime: runtime,
+		client:  client,
+		config:  config,
+		errors:  make(chan error, 10),
+	}
+}
+
+// Errors returns the errors of the messages that could not be bridged, the oldest error is dropped if
+// the channel is not read
+func (b *Bridge) Errors() <-chan error {
+	return b.errors
+}
+
+func (b *Bridge) Start() error {
+	if b.runtime == nil || b.client == nil {
+		return errors.New("Start() bridge runtime and client can not be empty")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscriptions) > 0 || len(b.inbound) > 0 {
+		return errors.New("Start() bridge has already been started")
+	}
+	for _, outbound := range b.config.Outbound {
+		outbound := outbound
+		subscription, err := b.runtime.GetEventBus().Subscribe(outbound.Topic, func(msg *reactive.Message) {
+			b.publish(outbound, msg)
+		}, &reactive.SubscribeOptions{Policy: reactive.DeliveryLatest})
+		if err != nil {
+			b.stop()
+			return fmt.Errorf("Start() outbound %w", err)
+		}
+		b.subscriptions = append(b.subscriptions, subscription)
+	}
+	for _, inbound := range b.config.Inbound {
+		inbound := inbound
+		err := b.client.Subscribe(inbound.Topic, inbound.QoS, func(topic string, payload []byte) {
+			if err := b.inject(inbound, topic, payload); err != nil {
+				b.reportError(err)
+			}
+		})
+		if err != nil {
+			b.stop()
+			return fmt.Errorf("Start() inbound %w", err)
+		}
+		b.inbound = append(b.inbound, inbound.Topic)
+	}
+	return nil
+}
+
+func (b *Bridge) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stop()
+}
+
+func (b *Bridge) stop() {
+	for _, subscription := range b.subscriptions {
+		subscription.Unsubscribe()
+	}
+	b.subscriptions = nil
+	for _, topic := range b.inbound {
+		if err := b.client.Unsubscribe(topic); err != nil {
+			b.reportError(err)
+		}
+	}
+	b.inbound = nil
+}
+
+// MQTTTopic returns the MQTT topic an EventBus topic is mirrored to
+func (o *Outbound) MQTTTopic(busTopic string) string {
+	return joinTopic(o.Prefix, busTopic)
+}
+
+func (b *Bridge) publish(outbound *Outbound, msg *reactive.Message) {
+	if msg == nil || msg.Port == nil || strings.HasPrefix(msg.Port.WrittenBy, writtenByPrefix) {
+		return
+	}
+	topic := outbound.MQTTTopic(msg.Topic)
+	if b.isInbound(topic) {
+		return
+	}
+	payload, err := json.Marshal(msg.Port)
+	if err != nil {
+		b.reportError(fmt.Errorf("failed to encode port %s: %w", msg.Port.ID, err))
+		return
+	}
+	if err := b.client.Publish(topic, outbound.QoS, outbound.Retain, payload); err != nil {
+		b.reportError(err)
+	}
+}
+
+// isInbound returns true if the MQTT topic matches an inbound topic, so a message would be sent back to the bridge
+func (b *Bridge) isInbound(topic string) bool {
+	for _, inbound := range b.config.Inbound {
+		if reactive.TopicMatch(inbound.Topic, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// inject writes the MQTT message to the node input port, the value is validated against the data type of the input
+func (b *Bridge) inject(inbound *Inbound, topic string, payload []byte) error {
+	nodeUUID, portID := inbound.NodeUUID, inbound.PortID
+	levels := strings.Split(topic, reactive.TopicSeparator)
+	if portID == "" && len(levels) > 0 {
+		portID = levels[len(levels)-1]
+	}
+	if nodeUUID == "" && len(levels) > 1 {
+		nodeUUID = levels[len(levels)-2]
+	}
+	value, err := decodeValue(payload)
+	if err != nil {
+		return fmt.Errorf("inbound topic %s: %w", topic, err)
+	}
+	_, err = b.runtime.WritePort(&reactive.PortWrite{
+		NodeUUID:  nodeUUID,
+		PortID:    portID,
+		Value:     value,
+		WrittenBy: writtenByPrefix + topic,
+	})
+	if err != nil {
+		return fmt.Errorf("inbound topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// decodeValue takes the value from a port JSON object, a plain JSON value or else the payload as text
+func decodeValue(payload []byte) (any, error) {
+	if len(payload) == 0 {
+		return nil, errors.New("payload can not be empty")
+	}
+	var raw any
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return string(payload), nil
+	}
+	if object, ok := raw.(map[string]any); ok {
+		if value, ok := object["value"]; ok {
+			return value, nil
+		}
+	}
+	return raw, nil
+}
+
+func (b *Bridge) reportError(err error) {
+	for {
+		select {
+		case b.errors <- err:
+			return
+		default:
+		}
+		select {
+		case <-b.errors:
+		default:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"github.com/NubeIO/reactive"
+	"testing"
+	"time"
+)
+
+func newNode(t *testing.T, runtime *reactive.Runtime, nodeUUID string) *reactive.BaseNode {
+	node := reactive.NewBaseNode(reactive.NodeInfo("point", nodeUUID, "point", "modbus"), runtime.GetEventBus(), nil)
+	node.NewInputPort("in", "in", "float")
+	node.NewOutputPort("out", "out", "float")
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func TestBridge(t *testing.T) {
+	runtime := reactive.NewRuntime(nil)
+	node := newNode(t, runtime, "abc")
+	broker := NewMemoryBroker()
+	bridge := New(runtime, broker, &Config{
+		Outbound: []*Outbound{{Topic: reactive.NodeTopic("abc"), Prefix: "site1", Retain: true}},
+		Inbound:  []*Inbound{{Topic: "site1/write/+/+"}},
+	})
+	if err := bridge.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Stop()
+
+	node.PublishMessage(&reactive.Port{ID: "out", Name: "out", Value: 21.5, Direction: "output", DataType: "float"})
+	topic := "site1/modbus/point/abc/out"
+	deadline := time.Now().Add(time.Second)
+	payload, ok := broker.Retained(topic)
+	for !ok && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		payload, ok = broker.Retained(topic)
+	}
+	if !ok {
+		t.Fatalf("expected a retained message on %s", topic)
+	}
+	port := &reactive.Port{}
+	if err := json.Unmarshal(payload, port); err != nil {
+		t.Fatal(err)
+	}
+	if port.ID != "out" || port.Value != 21.5 {
+		t.Fatalf("unexpected payload %s", payload)
+	}
+
+	broker.Publish("site1/write/abc/in", 0, false, []byte(`{"value": 3}`))
+	select {
+	case msg := <-node.Bus["in"]:
+		if msg.Port.Value != float64(3) || msg.NodeUUID != "abc" {
+			t.Fatalf("unexpected message %+v", msg.Port)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the inbound message")
+	}
+	// the write matches the outbound topic of the node but is not mirrored back to the broker
+	time.Sleep(20 * time.Millisecond)
+	if payload, ok := broker.Retained("site1/modbus/point/abc/in"); ok {
+		t.Fatalf("the inbound write should not be mirrored back, got %s", payload)
+	}
+
+	broker.Publish("site1/write/missing/in", 0, false, []byte(`1`))
+	select {
+	case err := <-bridge.Errors():
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an error for an unknown node")
+	}
+}
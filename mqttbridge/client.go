@@ -0,0 +1,134 @@
+package mqttbridge
+
+import (
+	"github.com/NubeIO/reactive"
+	"strings"
+	"sync"
+)
+
+// Client is the MQTT client used by the bridge. The package does not ship a client for a broker eg; Mosquitto,
+// the caller must provide one backed by an MQTT library, MemoryBroker is only an in-process stand-in. A client
+// backed by paho (github.com/eclipse/paho.mqtt.golang) is:
+//
+//	type pahoClient struct {
+//		client mqtt.Client
+//	}
+//
+//	func (c *pahoClient) Publish(topic string, qos byte, retained bool, payload []byte) error {
+//		token := c.client.Publish(topic, qos, retained, payload)
+//		token.Wait()
+//		return token.Error()
+//	}
+//
+//	func (c *pahoClient) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error {
+//		token := c.client.Subscribe(topic, qos, func(_ mqtt.Client, msg mqtt.Message) {
+//			handler(msg.Topic(), msg.Payload())
+//		})
+//		token.Wait()
+//		return token.Error()
+//	}
+//
+//	func (c *pahoClient) Unsubscribe(topic string) error {
+//		token := c.client.Unsubscribe(topic)
+//		token.Wait()
+//		return token.Error()
+//	}
+type Client interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+	Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error
+	Unsubscribe(topic string) error
+}
+
+type brokerSubscription struct {
+	topic   string
+	handler func(topic string, payload []byte)
+}
+
+// MemoryBroker is an in-process stand-in for an MQTT broker, it keeps retained messages and delivers
+// each publish to the matching subscriptions before Publish returns
+type MemoryBroker struct {
+	mu            sync.Mutex
+	subscriptions []*brokerSubscription
+	retained      map[string][]byte
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		retained: make(map[string][]byte),
+	}
+}
+
+func (b *MemoryBroker) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	b.mu.Lock()
+	if retained {
+		if len(payload) == 0 {
+			delete(b.retained, topic)
+		} else {
+			b.retained[topic] = payload
+		}
+	}
+	var handlers []func(topic string, payload []byte)
+	for _, sub := range b.subscriptions {
+		if reactive.TopicMatch(sub.topic, topic) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	b.mu.Unlock()
+	for _, handler := range handlers {
+		handler(topic, payload)
+	}
+	return nil
+}
+
+// Subscribe adds the subscription and delivers the retained messages that match it
+func (b *MemoryBroker) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error {
+	if err := reactive.ValidateTopicPattern(topic); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.subscriptions = append(b.subscriptions, &brokerSubscription{topic: topic, handler: handler})
+	retained := make(map[string][]byte)
+	for t, payload := range b.retained {
+		if reactive.TopicMatch(topic, t) {
+			retained[t] = payload
+		}
+	}
+	b.mu.Unlock()
+	for t, payload := range retained {
+		handler(t, payload)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Unsubscribe(topic string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := b.subscriptions[:0]
+	for _, sub := range b.subscriptions {
+		if sub.topic != topic {
+			out = append(out, sub)
+		}
+	}
+	b.subscriptions = out
+	return nil
+}
+
+// Retained returns the retained message of a topic
+func (b *MemoryBroker) Retained(topic string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	payload, ok := b.retained[topic]
+	return payload, ok
+}
+
+// joinTopic joins topic levels and skips the empty ones
+func joinTopic(levels ...string) string {
+	var out []string
+	for _, level := range levels {
+		level = strings.Trim(level, reactive.TopicSeparator)
+		if level != "" {
+			out = append(out, level)
+		}
+	}
+	return strings.Join(out, reactive.TopicSeparator)
+}
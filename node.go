@@ -1,6 +1,7 @@
 package reactive
 
 import (
+	"context"
 	"fmt"
 	"github.com/NubeIO/reactive/tracer"
 	"github.com/NubeIO/schema"
@@ -8,16 +9,23 @@ import (
 	"gorm.io/gorm"
 	"log"
 	"reflect"
-	"sync"
+	"strings"
+	"time"
 )
 
 type portDataType string
 
 const (
-	portTypeAny    portDataType = "any"
-	portTypeFloat  portDataType = "float"
-	portTypeString portDataType = "string"
-	portTypeBool   portDataType = "bool"
+	portTypeAny       portDataType = "any"
+	portTypeFloat     portDataType = "float"
+	portTypeString    portDataType = "string"
+	portTypeBool      portDataType = "bool"
+	portTypeInt       portDataType = "int"
+	portTypeJSON      portDataType = "json"      // a JSON object
+	portTypeArray     portDataType = "array"     // a JSON array
+	portTypeTimestamp portDataType = "timestamp" // encoded as an RFC3339 string
+	portTypeEnum      portDataType = "enum"      // one of the allowed values of the port
+	portTypeBytes     portDataType = "bytes"     // encoded as a base64 string
 )
 
 type flowDirection string
@@ -41,23 +49,46 @@ type Details struct {
 	HasLogger bool    `json:"hasLogger"`
 }
 
+// ScanNode is a node that runs in scan mode, a node opts in by adding Scan() next to Start(). Scan is called once
+// per scan with the values of the inputs by port id and returns the values of the outputs by port id.
+type ScanNode interface {
+	Node
+	Scan(ctx context.Context, inputs map[string]any) (map[string]any, error)
+}
+
+// baseNodeHooks are the hooks of a BaseNode the runtime calls, a node that embeds a BaseNode has them. They are
+// checked with a type assertion so a node of another package only has to implement Node, see publishPort(),
+// restoreLastValues(), stampPort(), writeInput() and latchInput() for what such a node gets instead.
+type baseNodeHooks interface {
+	publishPort(port *Port)
+	restoreLastValues(ports []*Port)
+	stamp(port *Port)
+	writeInput(write *PortWrite, value any) (*Port, error)
+	setLastValue(port *Port)
+}
+
 type Node interface {
 	New(nodeUUID, name string, bus *EventBus, settings *Settings, opts *Options) Node
 	SetDetails(details *Details)
 	GetDetails() *Details
-	Start()
-	Delete()
+	Init() error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Delete() error
 	GetUUID() string
 	GetParentUUID() string
 	GetPluginName() string
 	GetApplicationUse() string
 	GetID() string
 	GetNodeName() string
+	SetNodeName(name string)
 	NewPort(port *Port)
 	GetInput(id string) *Port
 	GetInputs() []*Port
 	GetOutputs() []*Port
 	SetInputValue(id string, value interface{})
+	GetInputValue(id string) interface{}
+	WriteInputPriority(id string, priority int, value any, writtenBy string) (any, error)
 	GetAllNodeValues() []*NodeValue
 	GetAllPortValues() []*Port
 	GetAllInputValues() []*Port
@@ -73,21 +104,24 @@ type Node interface {
 	GetData() map[string]any
 	setMeta(opts *Options)
 	GetMeta() *Meta
-	AddConnection(connection *Connection)
+	AddConnection(connection *Connection) error
+	RemoveConnection(connection *Connection)
+	HasConnection(connection *Connection) bool
 	GetConnections() []*Connection
-	UpdateConnections(connections []*Connection)
+	UpdateConnections(connections []*Connection) error
 	UpdateSettings(settings *Settings)
 	SetHotFix()
 	HotFix() bool
+	SetCycleBreaker()
+	CycleBreaker() bool
 	SetLoaded(set bool)
 	Loaded() bool
 	NotLoaded() bool
-	AddRuntime(runtimeNodes map[string]Node)
-	GetRuntimeNodes() map[string]Node
-	AddToNodeToRuntime(node Node) Node
-	RemoveNodeFromRuntime()
+	AddRuntime(runtime *Runtime)
+	GetRuntime() *Runtime
 
 	RegisterChildNode(child Node)
+	UnregisterChildNode(uuid string)
 	GetChildNodes() []Node
 	GetChildNode(uuid string) Node
 	GetChildsByType(nodeID string) []Node
@@ -107,12 +141,61 @@ type Node interface {
 	GetLogger() *logrus.Logger
 }
 
+// publishPort publishes the port of a node by its publish policy, a node without the hooks publishes every port
+func publishPort(node Node, port *Port) {
+	if hooks, ok := node.(baseNodeHooks); ok {
+		hooks.publishPort(port)
+		return
+	}
+	node.SetLastValue(port)
+	if runtime := node.GetRuntime(); runtime != nil {
+		runtime.GetEventBus().Publish(PortTopic(node.GetPluginName(), node.GetID(), node.GetUUID(), port.ID), &Message{
+			Port:     port,
+			NodeUUID: node.GetUUID(),
+			NodeID:   node.GetID(),
+		})
+	}
+}
+
+// restoreLastValues restores the last values of a node, a node without the hooks gets them set as last values
+func restoreLastValues(node Node, ports []*Port) {
+	if hooks, ok := node.(baseNodeHooks); ok {
+		hooks.restoreLastValues(ports)
+		return
+	}
+	for _, port := range ports {
+		node.SetLastValue(port)
+	}
+}
+
+// latchInput sets the last value of an input without writing the input, so a priority array is left as it is and the
+// value is not saved, a node without the hooks gets it set with SetLastValue()
+func latchInput(node Node, port *Port) {
+	if hooks, ok := node.(baseNodeHooks); ok {
+		hooks.setLastValue(port)
+		return
+	}
+	node.SetLastValue(port)
+}
+
+// stampPort sets the quality, timestamp and sequence of a port, a node without the hooks gets no sequence
+func stampPort(node Node, port *Port) {
+	if hooks, ok := node.(baseNodeHooks); ok {
+		hooks.stamp(port)
+		return
+	}
+	if port.Quality == "" {
+		port.Quality = QualityGood
+	}
+	if port.Timestamp.IsZero() {
+		port.Timestamp = time.Now()
+	}
+}
+
 func (n *BaseNode) New(nodeUUID, name string, bus *EventBus, settings *Settings, opts *Options) Node {
 	return n
 }
 
-var runtimeNodesMutex sync.Mutex
-
 func (n *BaseNode) GetUUID() string {
 	return n.UUID
 }
@@ -125,10 +208,103 @@ func (n *BaseNode) GetOutputs() []*Port {
 	return n.Outputs
 }
 
-func (n *BaseNode) Start() {}
+// ---------------------------- LIFECYCLE -------------------------- //
+// a node goes through Init() -> Start(ctx) -> Stop(ctx) -> Delete()
+// a node that overrides Start() or Stop() should call the BaseNode method so its context and goroutines are managed
 
-func (n *BaseNode) Delete() {
-	n.RemoveNodeFromRuntime()
+// Init is called once when the node is added to a runtime, before Start()
+func (n *BaseNode) Init() error {
+	return nil
+}
+
+// Start gives the node its own context, it is cancelled by Stop() or when the parent ctx is cancelled. The
+// watchdogs of the inputs are started as well.
+func (n *BaseNode) Start(ctx context.Context) error {
+	n.mux.Lock()
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	// a node started again publishes its next value and restarts its heartbeats
+	for _, state := range n.publishStates {
+		state.reset()
+	}
+	n.mux.Unlock()
+	n.startWatchdogs()
+	return nil
+}
+
+// Stop cancels the node context and waits for the goroutines started with Go() to return or for ctx to be done,
+// the values held back by a publish policy are dropped
+func (n *BaseNode) Stop(ctx context.Context) error {
+	n.cancelContext()
+	n.stopPublishTimers()
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Stop() node %s did not stop in time: %w", n.UUID, ctx.Err())
+	}
+}
+
+// Delete cancels the node if it is still running, unsubscribes its connections and its tracer and removes it from
+// its runtime
+func (n *BaseNode) Delete() error {
+	n.cancelContext()
+	n.stopPublishTimers()
+	for _, connection := range append([]*Connection{}, n.Connections...) {
+		n.RemoveConnection(connection)
+	}
+	n.unsubscribeInputs()
+	if n.stopTrace != nil {
+		n.stopTrace()
+		n.stopTrace = nil
+	}
+	if n.runtime != nil {
+		n.runtime.removeNode(n.UUID)
+	}
+	return nil
+}
+
+func (n *BaseNode) cancelContext() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.cancel != nil {
+		n.cancel()
+	}
+}
+
+// Context returns the node context, it is done once the node is stopped
+func (n *BaseNode) Context() context.Context {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.ctx == nil {
+		return context.Background()
+	}
+	return n.ctx
+}
+
+// Go runs fn in a goroutine that Stop() will wait on, fn must return once ctx is done
+//
+//	n.Go(func(ctx context.Context) {
+//		for {
+//			select {
+//			case <-ctx.Done():
+//				return
+//			case msg := <-n.Bus["in"]:
+//				...
+//			}
+//		}
+//	})
+func (n *BaseNode) Go(fn func(ctx context.Context)) {
+	ctx := n.Context()
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		fn(ctx)
+	}()
 }
 
 func (n *BaseNode) HotFix() bool {
@@ -139,25 +315,43 @@ func (n *BaseNode) SetHotFix() {
 	n.allowHotFix = true
 }
 
-func (n *BaseNode) AddRuntime(runtimeNodes map[string]Node) {
-	n.runtimeNodes = runtimeNodes
+// CycleBreaker returns true if the node breaks a feedback loop, its outputs are not a dependency of the nodes it feeds
+func (n *BaseNode) CycleBreaker() bool {
+	return n.cycleBreaker
 }
 
-func (n *BaseNode) GetRuntimeNodes() map[string]Node {
-	return n.runtimeNodes
+// SetCycleBreaker marks the node as a delay or latch node so it can be used to close a feedback loop
+func (n *BaseNode) SetCycleBreaker() {
+	n.cycleBreaker = true
 }
 
-func (n *BaseNode) AddToNodeToRuntime(node Node) Node {
-	runtimeNodesMutex.Lock()
-	defer runtimeNodesMutex.Unlock()
-	n.runtimeNodes[node.GetUUID()] = node
-	return n.runtimeNodes[node.GetUUID()]
+// AddRuntime links the node to its runtime and subscribes its inputs to their topics. The node publishes and
+// subscribes on the EventBus of the runtime, a node that was built with another bus is moved to it.
+func (n *BaseNode) AddRuntime(runtime *Runtime) {
+	n.runtime = runtime
+	if runtime != nil && n.EventBus != runtime.GetEventBus() {
+		n.attachBus(runtime.GetEventBus())
+	}
+	n.subscribeInputs()
 }
 
-func (n *BaseNode) RemoveNodeFromRuntime() {
-	runtimeNodesMutex.Lock()
-	defer runtimeNodesMutex.Unlock()
-	delete(n.runtimeNodes, n.UUID)
+// attachBus moves the subscriptions of the connections of the node to bus
+func (n *BaseNode) attachBus(bus *EventBus) {
+	n.unsubscribeInputs()
+	n.EventBus = bus
+	for _, connection := range n.Connections {
+		if connection.subscription != nil {
+			connection.subscription.Unsubscribe()
+			connection.subscription = nil
+		}
+		if err := n.subscribeConnection(connection); err != nil {
+			n.log().Errorf("failed to subscribe connection to: (%s-%s) err: %s", connection.TargetUUID, connection.TargetPort, err.Error())
+		}
+	}
+}
+
+func (n *BaseNode) GetRuntime() *Runtime {
+	return n.runtime
 }
 
 func (n *BaseNode) SetLoaded(set bool) {
@@ -175,36 +369,33 @@ func (n *BaseNode) NotLoaded() bool {
 // ---------------------------- EVENT BUS -------------------------- //
 
 func (n *BaseNode) setPortTopic(portId string) string {
-	return fmt.Sprintf("%s-%s", n.UUID, portId)
+	return PortTopic(n.pluginName, n.ID, n.UUID, portId)
 }
 
-func (n *BaseNode) PublishMessage(port *Port, setLastValue ...bool) {
-	if port.Name == "" {
-		log.Fatalf("port name can not be empty")
-	}
-	topic := n.setPortTopic(port.ID)
-	m := &Message{
-		Port:     port,
-		NodeUUID: n.GetUUID(),
-		NodeID:   n.GetID(),
-	}
-	if len(setLastValue) >= 0 {
-		go n.SetLastValue(port)
-	}
-	go n.EventBus.Publish(topic, m)
-	fmt.Printf("Published message from node: (name: %s uuid: %s) to topic: %s value %v\n", n.GetID(), n.GetUUID(), topic, printValue(port.Value))
-
-	if len(n.EventBus.WS.Connections) > 0 {
-		fmt.Printf("Published WS from node: (name: %s uuid: %s) to topic: %s value %v\n", n.GetID(), n.GetUUID(), topic, printValue(port.Value))
-
-		msg := &Message{
-			Port:     port,
-			NodeUUID: n.GetUUID(),
-			NodeID:   n.GetID(),
+// setPathTopic returns the topic of a port under the names of its parents eg; $path/myFolder/bacnetPoint/<portID>
+func (n *BaseNode) setPathTopic(portId string) string {
+	levels := []string{topicLevel(n.Name), topicLevel(portId)}
+	visited := map[string]bool{n.UUID: true}
+	parentUUID := n.parentUUID
+	for parentUUID != "" && n.runtime != nil && !visited[parentUUID] {
+		parent := n.runtime.GetNode(parentUUID)
+		if parent == nil {
+			break
 		}
-		n.EventBus.WS.Broadcast <- msg
+		visited[parentUUID] = true
+		levels = append([]string{topicLevel(parent.GetNodeName())}, levels...)
+		parentUUID = parent.GetParentUUID()
 	}
+	return strings.Join(append([]string{PathTopicPrefix}, levels...), TopicSeparator)
+}
 
+// PublishMessage publishes the port of an output and sets it as its last value, the publish policy of the output
+// applies, see SetOutputPublishPolicy()
+func (n *BaseNode) PublishMessage(port *Port) {
+	if port.Name == "" {
+		log.Fatalf("port name can not be empty")
+	}
+	n.publishPort(port)
 }
 
 type Options struct {
@@ -0,0 +1,299 @@
+package reactive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync"
+	"time"
+)
+
+// ---------------------------- PERSISTED VALUES -------------------------- //
+// a ValueStore saves the last value of each port so a node resumes with its previous state after a restart, a
+// port can change many times between two flushes and only its latest value is written
+//
+//	db, err := tracer.InitDatabase("rx.db", &PortValue{})
+//	store, err := NewValueStore(db, time.Second)
+//	runtime.AddValueStore(store)
+
+// DefaultFlushInterval is how often a ValueStore writes the changed values when no interval is set
+const DefaultFlushInterval = time.Second
+
+// PortValue is the last value of a port as it is saved in the database
+type PortValue struct {
+	NodeUUID    string    `json:"nodeUUID" gorm:"type:varchar(255);primaryKey"`
+	PortID      string    `json:"portID" gorm:"type:varchar(255);primaryKey"`
+	Value       string    `json:"value"` // the value encoded as JSON
+	DataType    string    `json:"dataType"`
+	LastUpdated string    `json:"lastUpdated,omitempty"`
+	WrittenBy   string    `json:"writtenBy,omitempty"`
+	Priority    string    `json:"priority,omitempty"` // the priority array of the port encoded as JSON
+	Quality     string    `json:"quality,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type ValueStore struct {
+	db       *gorm.DB
+	interval time.Duration
+	mu       sync.Mutex
+	pending  map[string]*PortValue // the latest value of each changed port by node uuid and port id
+	cancel   context.CancelFunc
+	done     chan struct{}
+	logger   *logrus.Logger // logs the failed flushes, see AddLogger()
+}
+
+// NewValueStore migrates the PortValue table and starts writing the changed values every interval
+func NewValueStore(db *gorm.DB, interval time.Duration) (*ValueStore, error) {
+	if db == nil {
+		return nil, errors.New("NewValueStore() db can not be empty")
+	}
+	if err := db.AutoMigrate(&PortValue{}); err != nil {
+		return nil, fmt.Errorf("NewValueStore() failed to migrate port values: %w", err)
+	}
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ValueStore{
+		db:       db,
+		interval: interval,
+		pending:  make(map[string]*PortValue),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go s.run(ctx)
+	return s, nil
+}
+
+func (s *ValueStore) run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				s.log().Errorf("failed to save port values: %s", err.Error())
+			}
+		}
+	}
+}
+
+// Save queues the value of the port to be written on the next flush
+func (s *ValueStore) Save(nodeUUID string, port *Port) error {
+	if port == nil {
+		return errors.New("Save() port can not be empty")
+	}
+	value, err := json.Marshal(port.Value)
+	if err != nil {
+		return fmt.Errorf("Save() failed to encode the value of port %s: %w", port.ID, err)
+	}
+	var priority []byte
+	if port.Priority != nil {
+		if priority, err = json.Marshal(port.Priority); err != nil {
+			return fmt.Errorf("Save() failed to encode the priority array of port %s: %w", port.ID, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[nodeUUID+"/"+port.ID] = &PortValue{
+		NodeUUID:    nodeUUID,
+		PortID:      port.ID,
+		Value:       string(value),
+		DataType:    string(port.DataType),
+		LastUpdated: port.LastUpdated,
+		WrittenBy:   port.WrittenBy,
+		Priority:    string(priority),
+		Quality:     string(port.Quality),
+		Timestamp:   port.Timestamp,
+		UpdatedAt:   time.Now(),
+	}
+	return nil
+}
+
+// Flush writes the queued values in one batch, the values are queued again if the write fails
+func (s *ValueStore) Flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]*PortValue)
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	rows := make([]*PortValue, 0, len(pending))
+	for _, row := range pending {
+		rows = append(rows, row)
+	}
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error; err != nil {
+		s.mu.Lock()
+		for key, row := range pending {
+			if _, newer := s.pending[key]; !newer {
+				s.pending[key] = row
+			}
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("Flush() failed to save %d port values: %w", len(rows), err)
+	}
+	return nil
+}
+
+// Load returns the saved values of a node
+func (s *ValueStore) Load(nodeUUID string) ([]*PortValue, error) {
+	var rows []*PortValue
+	if err := s.db.Where("node_uuid = ?", nodeUUID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("Load() failed to load the port values of node %s: %w", nodeUUID, err)
+	}
+	return rows, nil
+}
+
+// Delete removes the saved and queued values of a node
+func (s *ValueStore) Delete(nodeUUID string) error {
+	s.mu.Lock()
+	for key, row := range s.pending {
+		if row.NodeUUID == nodeUUID {
+			delete(s.pending, key)
+		}
+	}
+	s.mu.Unlock()
+	if err := s.db.Where("node_uuid = ?", nodeUUID).Delete(&PortValue{}).Error; err != nil {
+		return fmt.Errorf("Delete() failed to delete the port values of node %s: %w", nodeUUID, err)
+	}
+	return nil
+}
+
+// AddLogger sets the logger of the store, the store of a runtime logs through the logger of the runtime when it
+// has none
+func (s *ValueStore) AddLogger(logger *logrus.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+func (s *ValueStore) GetLogger() *logrus.Logger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logger
+}
+
+func (s *ValueStore) log() *logrus.Logger {
+	if logger := s.GetLogger(); logger != nil {
+		return logger
+	}
+	return logrus.StandardLogger()
+}
+
+// Close stops the flush goroutine and writes the values that are still queued
+func (s *ValueStore) Close() error {
+	s.cancel()
+	<-s.done
+	return s.Flush()
+}
+
+// ---------------------------- RUNTIME PERSISTED VALUES -------------------------- //
+
+// AddValueStore saves the last values of the nodes in the store, a node added after this has its values restored
+// before it is started. A store without a logger logs through the logger of the runtime.
+func (r *Runtime) AddValueStore(store *ValueStore) {
+	if store != nil && store.GetLogger() == nil {
+		store.AddLogger(r.GetLogger())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.valueStore = store
+}
+
+func (r *Runtime) GetValueStore() *ValueStore {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.valueStore
+}
+
+// saveValue queues the last value of a port, it is called by SetLastValue()
+func (r *Runtime) saveValue(nodeUUID string, port *Port) {
+	store := r.GetValueStore()
+	if store == nil {
+		return
+	}
+	if err := store.Save(nodeUUID, port); err != nil {
+		r.GetLogger().Errorf("failed to save value of: (%s-%s) err: %s", nodeUUID, port.ID, err.Error())
+	}
+}
+
+// restoreValues sets the saved values of a node as its last values, a value is skipped if it no longer fits the
+// data type of its port. A restored value is uncertain until the port publishes a new value.
+func (r *Runtime) restoreValues(node Node) error {
+	store := r.GetValueStore()
+	if store == nil {
+		return nil
+	}
+	rows, err := store.Load(node.GetUUID())
+	if err != nil {
+		return err
+	}
+	ports := map[string]*Port{}
+	for _, port := range append(append([]*Port{}, node.GetInputs()...), node.GetOutputs()...) {
+		ports[port.ID] = port
+	}
+	var restored []*Port
+	for _, row := range rows {
+		port, ok := ports[row.PortID]
+		if !ok {
+			continue
+		}
+		var raw any
+		if err := json.Unmarshal([]byte(row.Value), &raw); err != nil {
+			continue
+		}
+		value, err := port.ConvertValue(raw)
+		if err != nil {
+			continue
+		}
+		if port.Priority != nil && row.Priority != "" {
+			// the saved array is decoded on its own so a row that does not fit leaves the input untouched
+			priority := &PriorityArray{}
+			if err := json.Unmarshal([]byte(row.Priority), priority); err != nil {
+				continue
+			}
+			effective, _ := priority.Effective()
+			if value, err = port.ConvertValue(effective); err != nil {
+				continue
+			}
+			port.Priority.set(priority)
+		}
+		restored = append(restored, &Port{
+			ID:          port.ID,
+			Name:        port.Name,
+			Value:       value,
+			LastUpdated: row.LastUpdated,
+			WrittenBy:   row.WrittenBy,
+			Direction:   port.Direction,
+			DataType:    port.DataType,
+			Enum:        port.Enum,
+			Priority:    port.Priority.copy(),
+			Quality:     QualityUncertain,
+			Timestamp:   row.Timestamp,
+		})
+	}
+	restoreLastValues(node, restored)
+	return nil
+}
+
+// restoreLastValues sets the last values without saving them again, a restored input also gets its value back
+// without writing it to its priority array
+func (n *BaseNode) restoreLastValues(ports []*Port) {
+	for _, port := range ports {
+		n.mux.Lock()
+		n.LastValue[port.ID] = port
+		n.mux.Unlock()
+		if in := n.GetInput(port.ID); in != nil && port.Direction == input {
+			n.setInputValue(in, copyValue(port.Value))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package reactive
+
+import (
+	"context"
+	"github.com/NubeIO/reactive/tracer"
+	"path/filepath"
+	"testing"
+)
+
+func TestValueStoreRestore(t *testing.T) {
+	db, err := tracer.InitDatabase(filepath.Join(t.TempDir(), "values.db"), &PortValue{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewValueStore(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	runtime := NewRuntime(nil)
+	runtime.AddValueStore(store)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	node.SetLastValue(&Port{ID: "out", Name: "out", Value: 1.0, Direction: output, DataType: portTypeFloat})
+	node.SetLastValue(&Port{ID: "out", Name: "out", Value: 2.0, Direction: output, DataType: portTypeFloat})
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "in", Value: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.Load("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("expected the writes to be coalesced to 2 rows, got %d", len(saved))
+	}
+
+	// a new runtime restores the values before the node is started
+	restarted := NewRuntime(nil)
+	restarted.AddValueStore(store)
+	node = newTestNode("a", "add", restarted.GetEventBus())
+	if err := restarted.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	out, err := node.GetPortValue("out")
+	if err != nil || out.Value != 2.0 {
+		t.Fatalf("expected out to be restored to 2, got %+v %v", out, err)
+	}
+	if node.GetInput("in").Value != 5.0 {
+		t.Fatalf("expected in to be restored to 5, got %v", node.GetInput("in").Value)
+	}
+
+	if err := restarted.RemoveNode("a"); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := store.Load("a"); len(saved) != 0 {
+		t.Fatal("expected the values to be deleted with the node")
+	}
+}
+
+func TestValueStoreRestorePriority(t *testing.T) {
+	db, err := tracer.InitDatabase(filepath.Join(t.TempDir(), "values.db"), &PortValue{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewValueStore(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	runtime := NewRuntime(nil)
+	runtime.AddValueStore(store)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	node.NewPriorityInputPort("sp", "sp", portTypeFloat, 20.0)
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "sp", Value: 5, Priority: 8}); err != nil {
+		t.Fatal(err)
+	}
+	// the last value keeps its own copy of the priority array
+	node.GetInput("sp").Priority.Write(8, 6.0, "")
+	if last, _ := node.GetPortValue("sp"); last.Priority.Level(8).Value != 5.0 {
+		t.Fatalf("expected the last value to keep priority 8 at 5, got %v", last.Priority.Level(8).Value)
+	}
+	if err := runtime.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// a saved array that does not fit the input is not restored
+	if err := db.Model(&PortValue{}).Where("node_uuid = ? AND port_id = ?", "a", "sp").
+		Update("priority", `{"levels":[null,null,null,null,null,null,null,{"value":"bad"}],"relinquishDefault":20}`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewRuntime(nil)
+	restarted.AddValueStore(store)
+	node = newTestNode("a", "add", restarted.GetEventBus())
+	node.NewPriorityInputPort("sp", "sp", portTypeFloat, 20.0)
+	node.GetInput("sp").Priority.Write(1, 1.0, "")
+	if err := restarted.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	if value, priority := node.GetInput("sp").Priority.Effective(); value != 1.0 || priority != 1 {
+		t.Fatalf("expected the priority array to be untouched, got %v at %d", value, priority)
+	}
+}
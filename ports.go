@@ -1,15 +1,27 @@
 package reactive
 
+import (
+	"time"
+)
+
 // ---------------------------- NODE PORTS -------------------------- //
 
 // Port represents a data port with an ID, Name, and Value.
 type Port struct {
-	ID          string        `json:"id"`
-	Name        string        `json:"name"`
-	Value       interface{}   `json:"value,omitempty"`
-	LastUpdated string        `json:"lastUpdated,omitempty"` // last time it got a message
-	Direction   portDirection `json:"direction"`
-	DataType    portDataType  `json:"dataType"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Value       interface{}    `json:"value,omitempty"`
+	LastUpdated string         `json:"lastUpdated,omitempty"` // last time it got a message
+	WrittenBy   string         `json:"writtenBy,omitempty"`   // who wrote the value, set on a write from the api
+	Quality     Quality        `json:"quality,omitempty"`
+	Timestamp   time.Time      `json:"timestamp"`          // when the value was read at its source
+	Sequence    uint64         `json:"sequence,omitempty"` // goes up by one for each value the port publishes
+	Direction   portDirection  `json:"direction"`
+	DataType    portDataType   `json:"dataType"`
+	Enum        []string       `json:"enum,omitempty"`     // the allowed values of an enum port
+	Priority    *PriorityArray `json:"priority,omitempty"` // set on an input that is commanded by priority
+	Watchdog    *Watchdog      `json:"watchdog,omitempty"` // set on an input that goes stale without messages
+	Publish     *PublishPolicy `json:"publish,omitempty"`  // set on an output that does not publish every value
 }
 
 func (n *BaseNode) NewInputPort(id, name string, dataType portDataType) {
@@ -34,11 +46,68 @@ func (n *BaseNode) NewOutputPort(id, name string, dataType portDataType) {
 	n.NewPort(port)
 }
 
+// NewEnumInputPort adds an enum input that only takes one of the allowed values
+func (n *BaseNode) NewEnumInputPort(id, name string, enum ...string) {
+	n.NewPort(&Port{
+		ID:        id,
+		Name:      name,
+		Direction: input,
+		DataType:  portTypeEnum,
+		Enum:      enum,
+	})
+}
+
+// NewEnumOutputPort adds an enum output that only takes one of the allowed values
+func (n *BaseNode) NewEnumOutputPort(id, name string, enum ...string) {
+	n.NewPort(&Port{
+		ID:        id,
+		Name:      name,
+		Direction: output,
+		DataType:  portTypeEnum,
+		Enum:      enum,
+	})
+}
+
 func (n *BaseNode) NewPort(port *Port) {
 	if port.Direction == input {
 		n.Inputs = append(n.Inputs, port)
 		n.Bus[port.ID] = make(chan *Message, 1)
+		if n.runtime != nil {
+			n.subscribeInput(port.ID)
+		}
 	} else if port.Direction == output {
 		n.Outputs = append(n.Outputs, port)
 	}
 }
+
+// subscribeInputs subscribes all the inputs to their own topic, it is called once the node is added to a runtime
+// so a node that is only a prototype in the registry does not subscribe
+func (n *BaseNode) subscribeInputs() {
+	for _, port := range n.Inputs {
+		n.subscribeInput(port.ID)
+	}
+}
+
+// subscribeInput subscribes the input to its own topic, so a value can be sent to the input by publishing a
+// message on the topic of the input port eg; from an MQTT broker
+func (n *BaseNode) subscribeInput(portID string) {
+	if n.EventBus == nil {
+		return
+	}
+	if existing, ok := n.inputSubscriptions[portID]; ok {
+		existing.Unsubscribe()
+	}
+	subscription, err := n.EventBus.SubscribeChannel(n.setPortTopic(portID), n.Bus[portID], &SubscribeOptions{Transform: n.inputReceived(portID)})
+	if err != nil {
+		n.log().Errorf("failed to subscribe input: (%s-%s) err: %s", n.UUID, portID, err.Error())
+		return
+	}
+	n.inputSubscriptions[portID] = subscription
+}
+
+func (n *BaseNode) unsubscribeInputs() {
+	for portID, subscription := range n.inputSubscriptions {
+		subscription.Unsubscribe()
+		delete(n.inputSubscriptions, portID)
+	}
+}
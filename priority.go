@@ -0,0 +1,196 @@
+package reactive
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ---------------------------- PRIORITY ARRAY -------------------------- //
+// an input with a priority array is commanded like a BACnet point; each writer eg; a safety interlock, an operator
+// or a schedule writes at its own priority from 1 (highest) to 16 (lowest) and the value of the input is the value
+// of the highest priority that is not null, or the relinquish default if all the priorities are null
+
+const (
+	PriorityLevels  = 16
+	DefaultPriority = 16 // the priority of a write that does not set one
+)
+
+// PriorityLevel is the value written at one priority and who wrote it
+type PriorityLevel struct {
+	Value     any    `json:"value"`
+	WrittenBy string `json:"writtenBy,omitempty"`
+	WrittenAt string `json:"writtenAt"`
+}
+
+type PriorityArray struct {
+	mu                sync.Mutex
+	levels            [PriorityLevels]*PriorityLevel // index 0 is priority 1
+	relinquishDefault any
+}
+
+func NewPriorityArray(relinquishDefault any) *PriorityArray {
+	return &PriorityArray{relinquishDefault: relinquishDefault}
+}
+
+// Write sets the value at a priority, a nil value relinquishes the priority. It returns the effective value.
+func (p *PriorityArray) Write(priority int, value any, writtenBy string) (any, error) {
+	if priority < 1 || priority > PriorityLevels {
+		return nil, fmt.Errorf("priority %d must be from 1 to %d", priority, PriorityLevels)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if value == nil {
+		p.levels[priority-1] = nil
+	} else {
+		p.levels[priority-1] = &PriorityLevel{
+			Value:     value,
+			WrittenBy: writtenBy,
+			WrittenAt: time.Now().Format(time.RFC3339),
+		}
+	}
+	value, _ = p.effective()
+	return value, nil
+}
+
+// Relinquish clears the value at a priority, it returns the effective value
+func (p *PriorityArray) Relinquish(priority int) (any, error) {
+	return p.Write(priority, nil, "")
+}
+
+// Effective returns the value of the highest priority that is set and that priority, or the relinquish default
+// and a priority of 0 when no priority is set
+func (p *PriorityArray) Effective() (any, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.effective()
+}
+
+func (p *PriorityArray) effective() (any, int) {
+	for i, level := range p.levels {
+		if level != nil {
+			return level.Value, i + 1
+		}
+	}
+	return p.relinquishDefault, 0
+}
+
+// Level returns the value written at a priority, it is nil if the priority is not set
+func (p *PriorityArray) Level(priority int) *PriorityLevel {
+	if priority < 1 || priority > PriorityLevels {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if level := p.levels[priority-1]; level != nil {
+		out := *level
+		return &out
+	}
+	return nil
+}
+
+func (p *PriorityArray) RelinquishDefault() any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.relinquishDefault
+}
+
+func (p *PriorityArray) SetRelinquishDefault(value any) any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.relinquishDefault = value
+	value, _ = p.effective()
+	return value
+}
+
+// copy returns a snapshot of the priority array
+func (p *PriorityArray) copy() *PriorityArray {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := &PriorityArray{relinquishDefault: p.relinquishDefault}
+	for i, level := range p.levels {
+		if level != nil {
+			l := *level
+			out.levels[i] = &l
+		}
+	}
+	return out
+}
+
+// set replaces the levels and relinquish default of the priority array with those of from
+func (p *PriorityArray) set(from *PriorityArray) {
+	from = from.copy()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.levels = from.levels
+	p.relinquishDefault = from.relinquishDefault
+}
+
+type priorityArrayJSON struct {
+	Levels            []*PriorityLevel `json:"levels"` // index 0 is priority 1, a null level is not set
+	RelinquishDefault any              `json:"relinquishDefault"`
+	Active            int              `json:"active"` // the priority of the effective value, 0 is the relinquish default
+}
+
+func (p *PriorityArray) MarshalJSON() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, active := p.effective()
+	return json.Marshal(&priorityArrayJSON{
+		Levels:            p.levels[:],
+		RelinquishDefault: p.relinquishDefault,
+		Active:            active,
+	})
+}
+
+func (p *PriorityArray) UnmarshalJSON(data []byte) error {
+	in := &priorityArrayJSON{}
+	if err := json.Unmarshal(data, in); err != nil {
+		return err
+	}
+	if len(in.Levels) > PriorityLevels {
+		return fmt.Errorf("priority array can not have more than %d levels", PriorityLevels)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.relinquishDefault = in.RelinquishDefault
+	p.levels = [PriorityLevels]*PriorityLevel{}
+	copy(p.levels[:], in.Levels)
+	return nil
+}
+
+// ---------------------------- NODE PRIORITY -------------------------- //
+
+// NewPriorityInputPort adds an input with a priority array, its value is the relinquish default until it is written
+func (n *BaseNode) NewPriorityInputPort(id, name string, dataType portDataType, relinquishDefault any) {
+	n.NewPort(&Port{
+		ID:        id,
+		Name:      name,
+		Value:     relinquishDefault,
+		Direction: input,
+		DataType:  dataType,
+		Priority:  NewPriorityArray(relinquishDefault),
+	})
+}
+
+// WriteInputPriority writes the value of an input at a priority and sets the input to the effective value,
+// a nil value relinquishes the priority
+func (n *BaseNode) WriteInputPriority(id string, priority int, value any, writtenBy string) (any, error) {
+	port := n.GetInput(id)
+	if port == nil {
+		return nil, fmt.Errorf("WriteInputPriority() node %s has no input %s", n.UUID, id)
+	}
+	if port.Priority == nil {
+		return nil, fmt.Errorf("WriteInputPriority() input %s has no priority array", id)
+	}
+	effective, err := port.Priority.Write(priority, value, writtenBy)
+	if err != nil {
+		return nil, fmt.Errorf("WriteInputPriority() %w", err)
+	}
+	n.setInputValue(port, effective)
+	return effective, nil
+}
@@ -0,0 +1,66 @@
+package reactive
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPriorityArray(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	node.NewPriorityInputPort("setpoint", "setpoint", portTypeFloat, 21.0)
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	write := func(priority int, value any, writtenBy string) *Port {
+		t.Helper()
+		port, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "setpoint", Value: value, Priority: priority, WrittenBy: writtenBy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return port
+	}
+
+	if port := write(0, 22, "schedule"); port.Value != 22.0 {
+		t.Fatalf("expected a write without a priority to be at priority 16, got %v", port.Value)
+	}
+	if port := write(8, "18", "operator"); port.Value != 18.0 {
+		t.Fatalf("expected priority 8 to win, got %v", port.Value)
+	}
+	if port := write(16, 25, "schedule"); port.Value != 18.0 {
+		t.Fatalf("expected priority 8 to still win, got %v", port.Value)
+	}
+	if port := write(8, nil, "operator"); port.Value != 25.0 {
+		t.Fatalf("expected priority 16 after relinquishing 8, got %v", port.Value)
+	}
+	if level := node.GetInput("setpoint").Priority.Level(16); level == nil || level.WrittenBy != "schedule" {
+		t.Fatalf("unexpected level %+v", level)
+	}
+	if port := write(16, nil, ""); port.Value != 21.0 {
+		t.Fatalf("expected the relinquish default, got %v", port.Value)
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "setpoint", Value: 1, Priority: 17}); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue for priority 17, got %v", err)
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "in", Value: 1, Priority: 8}); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue for an input without a priority array, got %v", err)
+	}
+
+	write(5, 19, "interlock")
+	var values []*Port
+	data, _ := json.Marshal(node.GetAllPortValues())
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatal(err)
+	}
+	for _, port := range values {
+		if port.ID != "setpoint" {
+			continue
+		}
+		if level := port.Priority.Level(5); port.Value != 19.0 || level == nil || level.WrittenBy != "interlock" {
+			t.Fatalf("unexpected port value %s", data)
+		}
+		return
+	}
+	t.Fatal("priority input not found in the port values")
+}
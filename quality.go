@@ -0,0 +1,52 @@
+package reactive
+
+import (
+	"time"
+)
+
+// ---------------------------- QUALITY -------------------------- //
+// each value carries its quality, the time it was read at the source and a sequence number that goes up by one
+// for each value a port publishes, so downstream logic can ignore stale or failed readings and spot missed values
+
+type Quality string
+
+const (
+	QualityGood       Quality = "good"
+	QualityUncertain  Quality = "uncertain"  // eg; a value restored after a restart
+	QualityBad        Quality = "bad"        // the value is out of range or failed to be converted
+	QualityStale      Quality = "stale"      // the value was not updated in time
+	QualityOverridden Quality = "overridden" // the value was written by an operator
+	QualityCommFail   Quality = "comm-fail"  // the device the value is read from did not respond
+)
+
+// IsGood returns true if the value can be used, a value without a quality is good
+func (q Quality) IsGood() bool {
+	return q == "" || q == QualityGood || q == QualityOverridden
+}
+
+// IsGood returns true if the quality of the value is good, see Quality.IsGood()
+func (p *Port) IsGood() bool {
+	return p != nil && p.Quality.IsGood()
+}
+
+// stamp sets the quality and source timestamp of the port if they are not set and gives it the next sequence number
+func (n *BaseNode) stamp(port *Port) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.stampLocked(port)
+}
+
+// stampLocked is stamp() for a caller that holds n.mux
+func (n *BaseNode) stampLocked(port *Port) {
+	if port.Quality == "" {
+		port.Quality = QualityGood
+	}
+	if port.Timestamp.IsZero() {
+		port.Timestamp = time.Now()
+	}
+	if n.sequences == nil {
+		n.sequences = make(map[string]uint64)
+	}
+	n.sequences[port.ID]++
+	port.Sequence = n.sequences[port.ID]
+}
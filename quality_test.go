@@ -0,0 +1,71 @@
+package reactive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQualityPropagation(t *testing.T) {
+	runtime := NewRuntime(nil)
+	source := newTestNode("a", "modbus", runtime.GetEventBus())
+	target := newTestNode("b", "add", runtime.GetEventBus())
+	for _, node := range []Node{source, target} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	receive := func() *Port {
+		t.Helper()
+		select {
+		case msg := <-target.Bus["in"]:
+			return msg.Port
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered")
+		}
+		return nil
+	}
+
+	read := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 1.0, Timestamp: read})
+	port := receive()
+	if port.Quality != QualityGood || !port.IsGood() || !port.Timestamp.Equal(read) || port.Sequence != 1 {
+		t.Fatalf("unexpected port %+v", port)
+	}
+
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 1.0, Quality: QualityCommFail})
+	port = receive()
+	if port.Quality != QualityCommFail || port.IsGood() || port.Timestamp.IsZero() || port.Sequence != 2 {
+		t.Fatalf("unexpected port %+v", port)
+	}
+	if last, _ := source.GetPortValue("out"); last.Quality != QualityCommFail || last.Sequence != 2 {
+		t.Fatalf("unexpected last value %+v", last)
+	}
+}
+
+func TestQualityPublishCopy(t *testing.T) {
+	runtime := NewRuntime(nil)
+	source := newTestNode("a", "modbus", runtime.GetEventBus())
+	if err := runtime.AddNode(source); err != nil {
+		t.Fatal(err)
+	}
+	// an output port that is published again is not changed by a publish
+	out := &Port{ID: "out", Name: "out", Value: 1.0, Quality: QualityCommFail}
+	source.PublishMessage(out)
+	first, _ := source.GetPortValue("out")
+	time.Sleep(time.Millisecond)
+	out.Quality = ""
+	source.PublishMessage(out)
+	second, _ := source.GetPortValue("out")
+	if !out.Timestamp.IsZero() || out.Sequence != 0 {
+		t.Fatalf("expected the port of the caller to not be stamped, got %+v", out)
+	}
+	if !second.Timestamp.After(first.Timestamp) {
+		t.Fatalf("expected a new timestamp for each publish, got %v and %v", first.Timestamp, second.Timestamp)
+	}
+	if first.Quality != QualityCommFail || second.Quality != QualityGood {
+		t.Fatalf("expected the quality of each publish, got %s and %s", first.Quality, second.Quality)
+	}
+}
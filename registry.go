@@ -0,0 +1,61 @@
+package reactive
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Registry holds a prototype of each node type that can be created from a flow, new nodes are made with Node.New()
+type Registry struct {
+	mu    sync.RWMutex
+	nodes map[string]Node
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		nodes: make(map[string]Node),
+	}
+}
+
+func registryKey(pluginName, nodeID string) string {
+	return fmt.Sprintf("%s/%s", pluginName, nodeID)
+}
+
+// Register adds a node prototype, it is keyed by its plugin name and node id
+func (r *Registry) Register(node Node) error {
+	if node == nil {
+		return errors.New("Register() node can not be empty")
+	}
+	if node.GetID() == "" {
+		return errors.New("Register() node id can not be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(node.GetPluginName(), node.GetID())
+	if _, exists := r.nodes[key]; exists {
+		return fmt.Errorf("Register() node %s has already been registered", key)
+	}
+	r.nodes[key] = node
+	return nil
+}
+
+func (r *Registry) Get(pluginName, nodeID string) (Node, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	node, exists := r.nodes[registryKey(pluginName, nodeID)]
+	if !exists {
+		return nil, fmt.Errorf("node %s has not been registered", registryKey(pluginName, nodeID))
+	}
+	return node, nil
+}
+
+func (r *Registry) GetAll() []Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Node, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		out = append(out, node)
+	}
+	return out
+}
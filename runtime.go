@@ -0,0 +1,421 @@
+package reactive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStopTimeout  = 5 * time.Second // how long RemoveNode() waits for a node to stop
+	defaultDrainTimeout = time.Second     // the most Shutdown() waits for the in-flight messages
+)
+
+// Runtime owns a flow of nodes, the connections between them and the EventBus they publish on.
+// Each runtime has its own lock so multiple independent flows can run in one process.
+type Runtime struct {
+	mu         sync.RWMutex
+	nodes      map[string]Node
+	order      []string // node uuids in the order they were added
+	eventBus   *EventBus
+	registry   *Registry
+	ctx        context.Context
+	cancel     context.CancelFunc
+	running    map[string]context.CancelFunc // cancel func of each started node
+	scanner    *scanner
+	valueStore *ValueStore
+	logger     *logrus.Logger
+}
+
+// NewRuntime creates a new Runtime, if bus is nil a new EventBus is created. The bus is closed by Shutdown().
+func NewRuntime(bus *EventBus) *Runtime {
+	if bus == nil {
+		bus = NewEventBus()
+	}
+	return &Runtime{
+		nodes:    make(map[string]Node),
+		eventBus: bus,
+		running:  make(map[string]context.CancelFunc),
+		scanner:  &scanner{},
+	}
+}
+
+func (r *Runtime) GetEventBus() *EventBus {
+	return r.eventBus
+}
+
+// ---------------------------- RUNTIME NODES -------------------------- //
+
+// AddNode adds a node to the runtime, links the node back to it and calls Init() on the node.
+// If the runtime has already been started the node is started as well.
+func (r *Runtime) AddNode(node Node) error {
+	return r.addNode(node, -1)
+}
+
+// addNode adds the node at index in the node order, an index of -1 appends the node
+func (r *Runtime) addNode(node Node, index int) error {
+	if node == nil {
+		return errors.New("AddNode() node can not be empty")
+	}
+	uuid := node.GetUUID()
+	if uuid == "" {
+		return errors.New("AddNode() node uuid can not be empty")
+	}
+	// the uuid is a level of the topics of the node, see PortTopic()
+	if err := ValidateTopicLevel(uuid); err != nil {
+		return fmt.Errorf("AddNode() invalid node uuid: %w", err)
+	}
+	r.mu.Lock()
+	if _, exists := r.nodes[uuid]; exists {
+		r.mu.Unlock()
+		return fmt.Errorf("AddNode() node with uuid %s already exists", uuid)
+	}
+	r.nodes[uuid] = node
+	if index < 0 || index >= len(r.order) {
+		r.order = append(r.order, uuid)
+	} else {
+		r.order = append(r.order[:index], append([]string{uuid}, r.order[index:]...)...)
+	}
+	started := r.ctx != nil
+	r.mu.Unlock()
+	node.AddRuntime(r)
+	if err := node.Init(); err != nil {
+		node.Delete()
+		r.removeNode(uuid)
+		return fmt.Errorf("AddNode() failed to init node %s: %w", uuid, err)
+	}
+	if err := r.restoreValues(node); err != nil {
+		r.GetLogger().Errorf("AddNode() failed to restore values of node: %s err: %s", uuid, err.Error())
+	}
+	if started {
+		return r.StartNode(uuid)
+	}
+	return nil
+}
+
+// RemoveNode stops and deletes a node and removes it from the runtime, its saved values are deleted as well.
+func (r *Runtime) RemoveNode(uuid string) error {
+	if err := r.deleteNode(uuid); err != nil {
+		return fmt.Errorf("RemoveNode() %w", err)
+	}
+	if store := r.GetValueStore(); store != nil {
+		return store.Delete(uuid)
+	}
+	return nil
+}
+
+// deleteNode stops and deletes a node and removes it from the runtime, its saved values are kept
+func (r *Runtime) deleteNode(uuid string) error {
+	node := r.GetNode(uuid)
+	if node == nil {
+		return fmt.Errorf("node with uuid %s not found", uuid)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStopTimeout)
+	defer cancel()
+	stopErr := r.StopNode(ctx, uuid)
+	err := node.Delete()
+	r.removeNode(uuid)
+	return errors.Join(stopErr, err)
+}
+
+// nodeIndex returns the position of the node in the node order or -1
+func (r *Runtime) nodeIndex(uuid string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, id := range r.order {
+		if id == uuid {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeNode only drops the node from the registry and from its parent, it is safe to call more than once.
+func (r *Runtime) removeNode(uuid string) {
+	r.mu.Lock()
+	node, exists := r.nodes[uuid]
+	if !exists {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.nodes, uuid)
+	for i, id := range r.order {
+		if id == uuid {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	r.mu.Unlock()
+	r.unregisterChildNode(node, node.GetParentUUID())
+}
+
+func (r *Runtime) GetNode(uuid string) Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nodes[uuid]
+}
+
+// GetNodes returns all the nodes in the order they were added.
+func (r *Runtime) GetNodes() []Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Node, 0, len(r.order))
+	for _, uuid := range r.order {
+		out = append(out, r.nodes[uuid])
+	}
+	return out
+}
+
+// GetNodesByID returns all nodes of a node type, eg; all the add nodes
+func (r *Runtime) GetNodesByID(nodeID string) []Node {
+	var out []Node
+	for _, node := range r.GetNodes() {
+		if node.GetID() == nodeID {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+func (r *Runtime) GetNodesByPlugin(pluginName string) []Node {
+	var out []Node
+	for _, node := range r.GetNodes() {
+		if node.GetPluginName() == pluginName {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+func (r *Runtime) NodeCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.nodes)
+}
+
+// ---------------------------- RUNTIME CONNECTIONS -------------------------- //
+
+// AddConnection adds the connection to its target node, the target is the subscriber of the source port.
+func (r *Runtime) AddConnection(connection *Connection) error {
+	if connection == nil {
+		return errors.New("AddConnection() connection can not be empty")
+	}
+	if r.GetNode(connection.SourceUUID) == nil {
+		return fmt.Errorf("AddConnection() source node with uuid %s not found", connection.SourceUUID)
+	}
+	target := r.GetNode(connection.TargetUUID)
+	if target == nil {
+		return fmt.Errorf("AddConnection() target node with uuid %s not found", connection.TargetUUID)
+	}
+	if err := r.checkCycle(connection); err != nil {
+		return fmt.Errorf("AddConnection() %w", err)
+	}
+	return target.AddConnection(connection)
+}
+
+// sourcePort returns the output the connection subscribes to
+func (r *Runtime) sourcePort(connection *Connection) (*Port, error) {
+	source := r.GetNode(connection.SourceUUID)
+	if source == nil {
+		return nil, fmt.Errorf("source node with uuid %s not found", connection.SourceUUID)
+	}
+	for _, port := range source.GetOutputs() {
+		if port.ID == connection.SourcePort {
+			return port, nil
+		}
+	}
+	return nil, fmt.Errorf("source node %s has no output %s", connection.SourceUUID, connection.SourcePort)
+}
+
+// RemoveConnection removes the connection from its target node
+func (r *Runtime) RemoveConnection(connection *Connection) error {
+	if connection == nil {
+		return errors.New("RemoveConnection() connection can not be empty")
+	}
+	target := r.GetNode(connection.TargetUUID)
+	if target == nil {
+		return fmt.Errorf("RemoveConnection() target node with uuid %s not found", connection.TargetUUID)
+	}
+	if !target.HasConnection(connection) {
+		return fmt.Errorf("RemoveConnection() connection from %s:%s to %s:%s not found",
+			connection.SourceUUID, connection.SourcePort, connection.TargetUUID, connection.TargetPort)
+	}
+	target.RemoveConnection(connection)
+	return nil
+}
+
+// GetConnections returns the connections of all nodes in the runtime.
+func (r *Runtime) GetConnections() []*Connection {
+	var out []*Connection
+	for _, node := range r.GetNodes() {
+		out = append(out, node.GetConnections()...)
+	}
+	return out
+}
+
+// ---------------------------- RUNTIME LIFECYCLE -------------------------- //
+
+// Start starts all the nodes in topological order so a node is started after the nodes it depends on, each node
+// gets its own context derived from ctx.
+func (r *Runtime) Start(ctx context.Context) error {
+	r.mu.Lock()
+	if r.ctx != nil {
+		r.mu.Unlock()
+		return errors.New("Start() runtime has already been started")
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	r.mu.Unlock()
+	var errs []error
+	for _, uuid := range r.startOrder() {
+		if err := r.StartNode(uuid); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StartNode starts a single node, the runtime must have been started.
+func (r *Runtime) StartNode(uuid string) error {
+	node := r.GetNode(uuid)
+	if node == nil {
+		return fmt.Errorf("StartNode() node with uuid %s not found", uuid)
+	}
+	r.mu.Lock()
+	if r.ctx == nil {
+		r.mu.Unlock()
+		return errors.New("StartNode() runtime has not been started")
+	}
+	if _, running := r.running[uuid]; running {
+		r.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.running[uuid] = cancel
+	r.mu.Unlock()
+	if err := node.Start(ctx); err != nil {
+		r.mu.Lock()
+		delete(r.running, uuid)
+		r.mu.Unlock()
+		cancel()
+		return fmt.Errorf("StartNode() failed to start node %s: %w", uuid, err)
+	}
+	return nil
+}
+
+// StopNode cancels the node context and waits for it to stop or for ctx to be done.
+func (r *Runtime) StopNode(ctx context.Context, uuid string) error {
+	node := r.GetNode(uuid)
+	if node == nil {
+		return fmt.Errorf("StopNode() node with uuid %s not found", uuid)
+	}
+	r.mu.Lock()
+	cancel, running := r.running[uuid]
+	delete(r.running, uuid)
+	r.mu.Unlock()
+	if !running {
+		return nil
+	}
+	cancel()
+	return node.Stop(ctx)
+}
+
+// startOrder returns the topological order of the nodes, or the order they were added if there is a cycle
+func (r *Runtime) startOrder() []string {
+	g := r.Graph()
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return g.Nodes()
+	}
+	return order
+}
+
+// IsRunning returns true if the node has been started and not stopped
+func (r *Runtime) IsRunning(uuid string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, running := r.running[uuid]
+	return running
+}
+
+// Stop stops all the nodes in the reverse order they were started, the nodes stay in the runtime.
+func (r *Runtime) Stop(ctx context.Context) error {
+	r.StopScan()
+	order := r.startOrder()
+	var errs []error
+	for i := len(order) - 1; i >= 0; i-- {
+		if err := r.StopNode(ctx, order[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.ctx, r.cancel = nil, nil
+	r.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// Shutdown gracefully shuts the runtime down; it stops all the nodes, waits for the in-flight messages on the
+// EventBus to be delivered, deletes the nodes and then closes the EventBus. ctx sets the deadline for the whole
+// shutdown, the wait for the in-flight messages only gets a share of it as a stopped node no longer reads its inputs.
+func (r *Runtime) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := r.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	drainCtx, cancel := drainContext(ctx)
+	if err := r.eventBus.Wait(drainCtx); err != nil {
+		r.GetLogger().Warnf("Shutdown() dropping the messages not delivered: %s", err.Error())
+	}
+	cancel()
+	if store := r.GetValueStore(); store != nil {
+		if err := store.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	nodes := r.GetNodes()
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		if err := node.Delete(); err != nil {
+			errs = append(errs, err)
+		}
+		r.removeNode(node.GetUUID())
+	}
+	r.eventBus.Close()
+	return errors.Join(errs...)
+}
+
+// drainContext returns the context Shutdown() waits for the in-flight messages with, it is done after
+// defaultDrainTimeout or half of the time left before the deadline of ctx, whichever is sooner
+func drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := defaultDrainTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline) / 2; left < timeout {
+			timeout = left
+		}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ---------------------------- RUNTIME VALUES -------------------------- //
+
+func (r *Runtime) GetAllNodeValues() []*NodeValue {
+	nodes := r.GetNodes()
+	nodeValues := make([]*NodeValue, 0, len(nodes))
+	for _, node := range nodes {
+		nv := node.GetAllPortValues()
+		if nv == nil {
+			continue
+		}
+		nodeValues = append(nodeValues, &NodeValue{
+			NodeId:   node.GetID(),
+			NodeUUID: node.GetUUID(),
+			Ports:    nv,
+		})
+	}
+	return nodeValues
+}
@@ -0,0 +1,210 @@
+package reactive
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	*BaseNode
+}
+
+func newTestNode(nodeUUID, nodeID string, bus *EventBus) *testNode {
+	n := &testNode{BaseNode: NewBaseNode(NodeInfo(nodeID, nodeUUID, nodeID, "test-plugin"), bus, nil)}
+	n.NewInputPort("in", "in", portTypeFloat)
+	n.NewOutputPort("out", "out", portTypeFloat)
+	return n
+}
+
+func (n *testNode) New(nodeUUID, name string, bus *EventBus, settings *Settings, opts *Options) Node {
+	node := newTestNode(nodeUUID, n.GetID(), bus)
+	node.Name = name
+	node.AddSettings(settings)
+	node.setOptions(opts)
+	return node
+}
+
+func TestRuntimeNodes(t *testing.T) {
+	runtime := NewRuntime(nil)
+	a := newTestNode("a", "add", runtime.GetEventBus())
+	b := newTestNode("b", "sub", runtime.GetEventBus())
+	if err := runtime.AddNode(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.AddNode(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.AddNode(a); err == nil {
+		t.Fatal("expected an error when adding the same node twice")
+	}
+	if a.GetRuntime() != runtime {
+		t.Fatal("node was not linked to the runtime")
+	}
+	if len(runtime.GetNodesByID("sub")) != 1 || len(runtime.GetNodesByPlugin("test-plugin")) != 2 {
+		t.Fatal("unexpected lookup result")
+	}
+
+	a.SetLastValue(&Port{ID: "out", Name: "out", Value: 1.0})
+	if values := b.GetAllNodeValues(); len(values) != 1 || values[0].NodeUUID != "a" {
+		t.Fatalf("unexpected node values %+v", values)
+	}
+
+	if err := runtime.RemoveNode("a"); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GetNode("a") != nil || runtime.NodeCount() != 1 {
+		t.Fatal("node was not removed")
+	}
+
+	// a second runtime does not share nodes with the first
+	other := NewRuntime(nil)
+	if other.NodeCount() != 0 {
+		t.Fatal("runtimes must not share nodes")
+	}
+}
+
+func TestInputSubscriptions(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	if stats := runtime.GetEventBus().GetSubscriptionStats(); len(stats) != 0 {
+		t.Fatalf("a node that is not in a runtime should not subscribe its inputs, got %d subscriptions", len(stats))
+	}
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	node.NewInputPort("in2", "in2", portTypeFloat)
+	if stats := runtime.GetEventBus().GetSubscriptionStats(); len(stats) != 2 {
+		t.Fatalf("expected 2 input subscriptions got %d", len(stats))
+	}
+	if err := runtime.RemoveNode("a"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := runtime.GetEventBus().GetSubscriptionStats(); len(stats) != 0 {
+		t.Fatalf("expected no subscriptions after the node was removed got %d", len(stats))
+	}
+}
+
+func TestAddNodeAttachesBus(t *testing.T) {
+	runtime := NewRuntime(nil)
+	other := NewEventBus()
+	defer other.Close()
+	a := newTestNode("a", "add", runtime.GetEventBus())
+	// b is built with another bus and is connected before it is added
+	b := newTestNode("b", "add", other)
+	if err := b.AddConnection(&Connection{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []Node{a, b} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.EventBus != runtime.GetEventBus() {
+		t.Fatal("expected the node to publish on the bus of the runtime")
+	}
+	if stats := other.GetSubscriptionStats(); len(stats) != 0 {
+		t.Fatalf("expected no subscriptions left on the other bus got %d", len(stats))
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "b", PortID: "in", Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	a.PublishMessage(&Port{ID: "out", Name: "out", Value: 2.0})
+	// the write and the connection are delivered by their own subscriptions, in any order
+	received := map[any]bool{}
+	for len(received) < 2 {
+		select {
+		case msg := <-b.Bus["in"]:
+			received[msg.Port.Value] = true
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for the write and the connection, got %v", received)
+		}
+	}
+	if !received[1.0] || !received[2.0] {
+		t.Fatalf("unexpected values %v", received)
+	}
+}
+
+func TestRuntimeLifecycle(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	node.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	if !runtime.IsRunning("a") {
+		t.Fatal("node should be running")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := runtime.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("node goroutine was not stopped")
+	}
+	if runtime.NodeCount() != 0 {
+		t.Fatal("nodes should be deleted on shutdown")
+	}
+}
+
+func TestNodeStopDeadline(t *testing.T) {
+	node := newTestNode("a", "add", NewEventBus())
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	defer close(release)
+	node.Go(func(ctx context.Context) {
+		<-release // ignores ctx on purpose
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := node.Stop(ctx); err == nil {
+		t.Fatal("expected a deadline error")
+	}
+}
+
+func TestRuntimeShutdownUnreadInput(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// nothing reads the input, so the messages stay in flight
+	for i := 0; i < 5; i++ {
+		runtime.GetEventBus().Publish(node.setPortTopic("in"), &Message{Port: &Port{ID: "in", Value: float64(i)}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := runtime.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("shutdown took %s", elapsed)
+	}
+	if runtime.NodeCount() != 0 {
+		t.Fatal("nodes should be deleted on shutdown")
+	}
+	// the closed bus drops the messages, the delivery goroutines exit on their own
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer waitCancel()
+	if err := runtime.GetEventBus().Wait(waitCtx); err != nil {
+		t.Fatal(err)
+	}
+}
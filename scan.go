@@ -0,0 +1,245 @@
+package reactive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ---------------------------- SCAN MODE -------------------------- //
+// in scan mode the runtime evaluates the nodes like a PLC; on each tick each ScanNode is called once in topological
+// order, so a node reads the outputs its source nodes wrote in the same scan. The outputs of a CycleBreaker() node
+// that closes a loop are read from the previous scan. Nodes that are not a ScanNode keep running on their own from
+// the EventBus.
+
+// DefaultScanPeriod is the scan period used when ScanOptions does not set one
+const DefaultScanPeriod = 100 * time.Millisecond
+
+type ScanOptions struct {
+	Period time.Duration `json:"period"`
+}
+
+// ScanResult is the result of one scan
+type ScanResult struct {
+	Scan     uint64            `json:"scan"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration"`
+	Nodes    int               `json:"nodes"`            // the number of nodes that were scanned
+	Overrun  bool              `json:"overrun"`          // the scan took longer than the scan period
+	Errors   map[string]string `json:"errors,omitempty"` // by node uuid
+}
+
+// ScanStats are the statistics of all the scans since the runtime was created
+type ScanStats struct {
+	Running     bool          `json:"running"`
+	Period      time.Duration `json:"period"`
+	Scans       uint64        `json:"scans"`
+	Overruns    uint64        `json:"overruns"`
+	Errors      uint64        `json:"errors"` // the number of node errors
+	MinDuration time.Duration `json:"minDuration"`
+	MaxDuration time.Duration `json:"maxDuration"`
+	AvgDuration time.Duration `json:"avgDuration"`
+	Last        *ScanResult   `json:"last,omitempty"`
+}
+
+type scanner struct {
+	mu     sync.Mutex // held for a whole scan so scans never overlap
+	stats  ScanStats
+	total  time.Duration
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartScan runs a scan every period until StopScan() is called or the runtime is stopped
+func (r *Runtime) StartScan(opts *ScanOptions) error {
+	period := DefaultScanPeriod
+	if opts != nil && opts.Period > 0 {
+		period = opts.Period
+	}
+	if _, err := r.TopologicalOrder(); err != nil {
+		return fmt.Errorf("StartScan() %w", err)
+	}
+	r.mu.RLock()
+	parent := r.ctx
+	r.mu.RUnlock()
+	if parent == nil {
+		return errors.New("StartScan() runtime has not been started")
+	}
+	s := r.scanner
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return errors.New("StartScan() scan mode has already been started")
+	}
+	ctx, cancel := context.WithCancel(parent)
+	s.cancel, s.done = cancel, make(chan struct{})
+	s.stats.Running, s.stats.Period = true, period
+	done := s.done
+	s.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.scan(ctx, period)
+			}
+		}
+	}()
+	return nil
+}
+
+// StopScan stops scan mode and waits for the scan that is running to finish
+func (r *Runtime) StopScan() {
+	s := r.scanner
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.stats.Running = false
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// ScanOnce runs a single scan, it can be used to step the runtime without starting scan mode
+func (r *Runtime) ScanOnce(ctx context.Context) (*ScanResult, error) {
+	return r.scan(ctx, 0)
+}
+
+// GetScanStats returns the statistics of the scans
+func (r *Runtime) GetScanStats() *ScanStats {
+	s := r.scanner
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	if stats.Last != nil {
+		last := *stats.Last
+		stats.Last = &last
+	}
+	return &stats
+}
+
+// scan evaluates the nodes in topological order, a period of 0 never overruns
+func (r *Runtime) scan(ctx context.Context, period time.Duration) (*ScanResult, error) {
+	s := r.scanner
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, err := r.TopologicalOrder()
+	if err != nil {
+		return nil, fmt.Errorf("ScanOnce() %w", err)
+	}
+	result := &ScanResult{Scan: s.stats.Scans + 1, Start: time.Now(), Errors: map[string]string{}}
+	connections := map[string][]*Connection{}
+	for _, connection := range r.GetConnections() {
+		connections[connection.TargetUUID] = append(connections[connection.TargetUUID], connection)
+	}
+	for _, uuid := range order {
+		if ctx.Err() != nil {
+			break
+		}
+		node, ok := r.GetNode(uuid).(ScanNode)
+		if !ok {
+			continue
+		}
+		outputs, err := node.Scan(ctx, r.scanInputs(node, connections[uuid]))
+		result.Nodes++
+		if err != nil {
+			result.Errors[uuid] = err.Error()
+			continue
+		}
+		if err := r.writeScanOutputs(node, outputs); err != nil {
+			result.Errors[uuid] = err.Error()
+		}
+	}
+	result.Duration = time.Since(result.Start)
+	result.Overrun = period > 0 && result.Duration > period
+
+	s.stats.Scans++
+	s.stats.Errors += uint64(len(result.Errors))
+	if result.Overrun {
+		s.stats.Overruns++
+	}
+	if s.stats.Scans == 1 || result.Duration < s.stats.MinDuration {
+		s.stats.MinDuration = result.Duration
+	}
+	if result.Duration > s.stats.MaxDuration {
+		s.stats.MaxDuration = result.Duration
+	}
+	s.total += result.Duration
+	s.stats.AvgDuration = s.total / time.Duration(s.stats.Scans)
+	s.stats.Last = result
+	return result, nil
+}
+
+// scanInputs latches the inputs of the node; a connected input takes the last value of its source port, else
+// the input takes the last value sent to it or keeps the value that was written to it. A connected input only
+// latches its last value, its priority array is not written on each scan. The messages waiting on the Bus of the
+// node are left to the node.
+func (r *Runtime) scanInputs(node Node, connections []*Connection) map[string]any {
+	inputs := make(map[string]any, len(node.GetInputs()))
+	for _, input := range node.GetInputs() {
+		if last, err := node.GetPortValue(input.ID); err == nil {
+			inputs[input.ID] = last.Value
+		} else {
+			inputs[input.ID] = node.GetInputValue(input.ID)
+		}
+	}
+	for _, connection := range connections {
+		source := r.GetNode(connection.SourceUUID)
+		if source == nil {
+			continue
+		}
+		port, err := source.GetPortValue(connection.SourcePort)
+		if err != nil {
+			continue
+		}
+		msg := &Message{Port: &Port{ID: port.ID, Name: port.Name, Value: port.Value, DataType: port.DataType}}
+		if connection.transform != nil {
+			if msg = connection.transform(msg); msg == nil {
+				continue
+			}
+		}
+		inputs[connection.TargetPort] = msg.Port.Value
+		latched := inputPort(node.GetInput(connection.TargetPort), connection.TargetPort, msg.Port)
+		latched.Quality, latched.Timestamp = port.Quality, port.Timestamp
+		latchInput(node, latched)
+	}
+	return inputs
+}
+
+// writeScanOutputs publishes each output once, the publish policy of the output still applies
+func (r *Runtime) writeScanOutputs(node Node, outputs map[string]any) error {
+	var errs []error
+	now := time.Now().Format(time.RFC3339)
+	for _, output := range node.GetOutputs() {
+		value, ok := outputs[output.ID]
+		if !ok {
+			continue
+		}
+		value, err := output.ConvertValue(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("output %s: %w", output.ID, err))
+			continue
+		}
+		port := &Port{
+			ID:          output.ID,
+			Name:        output.Name,
+			Value:       value,
+			LastUpdated: now,
+			Direction:   output.Direction,
+			DataType:    output.DataType,
+			Enum:        output.Enum,
+		}
+		publishPort(node, port)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package reactive
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// scanAddNode adds one to its input on each scan
+type scanAddNode struct {
+	*testNode
+}
+
+func (n *scanAddNode) Scan(ctx context.Context, inputs map[string]any) (map[string]any, error) {
+	in, _ := inputs["in"].(float64)
+	return map[string]any{"out": in + 1}, nil
+}
+
+func TestScanOnce(t *testing.T) {
+	runtime := NewRuntime(nil)
+	// added in reverse so the scan order must come from the connections
+	c := &scanAddNode{newTestNode("c", "add", runtime.GetEventBus())}
+	b := &scanAddNode{newTestNode("b", "add", runtime.GetEventBus())}
+	a := &scanAddNode{newTestNode("a", "add", runtime.GetEventBus())}
+	async := newTestNode("async", "add", runtime.GetEventBus())
+	for _, node := range []Node{c, b, a, async} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, connection := range []*Connection{
+		{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"},
+		{SourceUUID: "b", SourcePort: "out", TargetUUID: "c", TargetPort: "in"},
+	} {
+		if err := runtime.AddConnection(connection); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "in", Value: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := runtime.ScanOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Nodes != 3 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	out, err := c.GetPortValue("out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != 13.0 {
+		t.Fatalf("expected the value to pass through all nodes in one scan, got %v", out.Value)
+	}
+	select {
+	case msg := <-a.Bus["in"]:
+		if msg.Port.Value != 10.0 {
+			t.Fatalf("expected the written value on the bus, got %v", msg.Port.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the scan to leave the written message on the bus of the node")
+	}
+	if stats := runtime.GetScanStats(); stats.Scans != 1 || stats.Last.Scan != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := runtime.StartScan(&ScanOptions{Period: time.Millisecond}); err == nil {
+		t.Fatal("expected an error when the runtime has not been started")
+	}
+	if err := runtime.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.StartScan(&ScanOptions{Period: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.GetScanStats().Scans < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := runtime.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := runtime.GetScanStats(); stats.Running || stats.Scans < 3 {
+		t.Fatalf("unexpected stats after stop %+v", stats)
+	}
+	if _, ok := Node(async).(ScanNode); ok {
+		t.Fatal("expected the BaseNode to not be a ScanNode")
+	}
+}
+
+func TestScanPriorityInput(t *testing.T) {
+	runtime := NewRuntime(nil)
+	a := &scanAddNode{newTestNode("a", "add", runtime.GetEventBus())}
+	b := &scanAddNode{newTestNode("b", "add", runtime.GetEventBus())}
+	b.NewPriorityInputPort("sp", "sp", portTypeFloat, 20.0)
+	for _, node := range []Node{a, b} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "sp"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := runtime.ScanOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the connected input latches the value of the connection, the priority array is not written
+	if last, err := b.GetPortValue("sp"); err != nil || last.Value != 1.0 {
+		t.Fatalf("expected the input to latch the connection value, got %v %v", last, err)
+	}
+	if value, priority := b.GetInput("sp").Priority.Effective(); value != 20.0 || priority != 0 {
+		t.Fatalf("expected the priority array to be left as it is, got %v at %d", value, priority)
+	}
+}
@@ -0,0 +1,25 @@
+package reactive
+
+// Subscription is the handle of a subscription to the EventBus
+type Subscription struct {
+	bus *EventBus
+	sub *subscriber
+}
+
+// Unsubscribe stops the delivery of messages and discards any buffered messages, it is safe to call more than once
+func (s *Subscription) Unsubscribe() {
+	if s == nil {
+		return
+	}
+	s.bus.unsubscribe(s.sub)
+}
+
+// Topic returns the topic pattern of the subscription
+func (s *Subscription) Topic() string {
+	return s.sub.topic
+}
+
+// Stats returns the delivery counters of the subscription
+func (s *Subscription) Stats() *SubscriptionStats {
+	return s.sub.stats()
+}
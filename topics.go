@@ -0,0 +1,221 @@
+package reactive
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ---------------------------- TOPICS -------------------------- //
+// topics are hierarchical like MQTT, a port is published on <pluginName>/<nodeID>/<nodeUUID>/<portID>
+// a subscription can use + to match one level and # to match all the remaining levels, eg;
+//   - +/+/<nodeUUID>/+   all the ports of one node
+//   - <pluginName>/#     all the ports of all the nodes of a plugin
+//
+// as in MQTT a topic whose first level starts with $ is reserved, it is only matched by a pattern that starts
+// with the same level eg; $path/# and not by +/+/+/+ or #
+
+const (
+	TopicSeparator   = "/"
+	TopicWildcardOne = "+"
+	TopicWildcardAll = "#"
+	// TracerTopicPrefix is the first level of the tracer topics
+	TracerTopicPrefix = "$tracer"
+	// PathTopicPrefix is the first level of the topics a port is published on under the names of its parents,
+	// see BaseNode.PublishOnTopic
+	PathTopicPrefix = "$path"
+	// topicReservedPrefix starts the first level of a reserved topic
+	topicReservedPrefix = "$"
+)
+
+var topicLevelReplacer = strings.NewReplacer(TopicSeparator, "_", TopicWildcardOne, "_", TopicWildcardAll, "_")
+
+// topicLevel makes a name safe to use as one level of a topic
+func topicLevel(name string) string {
+	return topicLevelReplacer.Replace(name)
+}
+
+// PortTopic returns the topic a port is published on
+func PortTopic(pluginName, nodeID, nodeUUID, portID string) string {
+	return strings.Join([]string{topicLevel(pluginName), topicLevel(nodeID), nodeUUID, topicLevel(portID)}, TopicSeparator)
+}
+
+// PortTopicByUUID returns a pattern that matches one port of a node by its uuid
+func PortTopicByUUID(nodeUUID, portID string) string {
+	return strings.Join([]string{TopicWildcardOne, TopicWildcardOne, nodeUUID, topicLevel(portID)}, TopicSeparator)
+}
+
+// NodeTopic returns a pattern that matches all the ports of a node
+func NodeTopic(nodeUUID string) string {
+	return strings.Join([]string{TopicWildcardOne, TopicWildcardOne, nodeUUID, TopicWildcardOne}, TopicSeparator)
+}
+
+// PluginTopic returns a pattern that matches all the ports of all the nodes of a plugin
+func PluginTopic(pluginName string) string {
+	return strings.Join([]string{topicLevel(pluginName), TopicWildcardAll}, TopicSeparator)
+}
+
+// TracerTopic returns the topic the tracer messages of a node are published on, eg; $tracer/<nodeUUID>/error
+func TracerTopic(nodeUUID, loggerType string) string {
+	return strings.Join([]string{TracerTopicPrefix, nodeUUID, topicLevel(loggerType)}, TopicSeparator)
+}
+
+// TracerTopicByNode returns a pattern that matches the tracer messages of all the levels of a node
+func TracerTopicByNode(nodeUUID string) string {
+	return strings.Join([]string{TracerTopicPrefix, nodeUUID, TopicWildcardOne}, TopicSeparator)
+}
+
+// ValidateTopic checks a topic can be published on, it can not contain wildcards
+func ValidateTopic(topic string) error {
+	if topic == "" {
+		return errors.New("topic can not be empty")
+	}
+	if strings.ContainsAny(topic, TopicWildcardOne+TopicWildcardAll) {
+		return fmt.Errorf("topic %s can not contain wildcards", topic)
+	}
+	return nil
+}
+
+// ValidateTopicLevel checks a name can be used as one level of a topic as it is, eg; a node uuid
+func ValidateTopicLevel(level string) error {
+	if level == "" {
+		return errors.New("topic level can not be empty")
+	}
+	if strings.ContainsAny(level, TopicSeparator+TopicWildcardOne+TopicWildcardAll) {
+		return fmt.Errorf("topic level %s can not contain %s, %s or %s", level, TopicSeparator, TopicWildcardOne, TopicWildcardAll)
+	}
+	return nil
+}
+
+// ValidateTopicPattern checks a subscription pattern, a wildcard must be a whole level and # must be the last level
+func ValidateTopicPattern(pattern string) error {
+	if pattern == "" {
+		return errors.New("topic can not be empty")
+	}
+	levels := strings.Split(pattern, TopicSeparator)
+	for i, level := range levels {
+		if level == TopicWildcardOne {
+			continue
+		}
+		if level == TopicWildcardAll {
+			if i != len(levels)-1 {
+				return fmt.Errorf("topic %s can only have %s as the last level", pattern, TopicWildcardAll)
+			}
+			continue
+		}
+		if strings.ContainsAny(level, TopicWildcardOne+TopicWildcardAll) {
+			return fmt.Errorf("topic %s has a wildcard that is not a whole level", pattern)
+		}
+	}
+	return nil
+}
+
+// TopicMatch returns true if the topic matches the subscription pattern
+func TopicMatch(pattern, topic string) bool {
+	p := strings.Split(pattern, TopicSeparator)
+	t := strings.Split(topic, TopicSeparator)
+	if reservedTopic(t) && (p[0] == TopicWildcardOne || p[0] == TopicWildcardAll) {
+		return false
+	}
+	for i, level := range p {
+		if level == TopicWildcardAll {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != TopicWildcardOne && level != t[i] {
+			return false
+		}
+	}
+	return len(p) == len(t)
+}
+
+// topicTree stores the subscribers of each pattern by its levels so a publish only walks the matching branches
+type topicTree struct {
+	children    map[string]*topicTree
+	subscribers []*subscriber
+}
+
+func newTopicTree() *topicTree {
+	return &topicTree{children: make(map[string]*topicTree)}
+}
+
+func (t *topicTree) add(pattern string, sub *subscriber) {
+	node := t
+	for _, level := range strings.Split(pattern, TopicSeparator) {
+		child, ok := node.children[level]
+		if !ok {
+			child = newTopicTree()
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.subscribers = append(node.subscribers, sub)
+}
+
+// remove removes the subscriber from the pattern and prunes the branches that are left empty
+func (t *topicTree) remove(levels []string, sub *subscriber) bool {
+	if len(levels) == 0 {
+		for i, existing := range t.subscribers {
+			if existing == sub {
+				t.subscribers = append(t.subscribers[:i], t.subscribers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+	child, ok := t.children[levels[0]]
+	if !ok {
+		return false
+	}
+	removed := child.remove(levels[1:], sub)
+	if len(child.subscribers) == 0 && len(child.children) == 0 {
+		delete(t.children, levels[0])
+	}
+	return removed
+}
+
+// reservedTopic returns true if the first level of the topic starts with $, see the topics above
+func reservedTopic(levels []string) bool {
+	return len(levels) > 0 && strings.HasPrefix(levels[0], topicReservedPrefix)
+}
+
+// matchTopic appends the subscribers of all the patterns that match the topic, a reserved topic is not matched by
+// a wildcard in the first level
+func (t *topicTree) matchTopic(topic string, out []*subscriber) []*subscriber {
+	levels := strings.Split(topic, TopicSeparator)
+	if reservedTopic(levels) {
+		if child, ok := t.children[levels[0]]; ok {
+			return child.match(levels[1:], out)
+		}
+		return out
+	}
+	return t.match(levels, out)
+}
+
+// match appends the subscribers of all the patterns that match the topic levels
+func (t *topicTree) match(levels []string, out []*subscriber) []*subscriber {
+	if all, ok := t.children[TopicWildcardAll]; ok {
+		out = append(out, all.subscribers...)
+	}
+	if len(levels) == 0 {
+		return append(out, t.subscribers...)
+	}
+	if child, ok := t.children[levels[0]]; ok {
+		out = child.match(levels[1:], out)
+	}
+	if child, ok := t.children[TopicWildcardOne]; ok {
+		out = child.match(levels[1:], out)
+	}
+	return out
+}
+
+// all appends every subscriber in the tree
+func (t *topicTree) all(out []*subscriber) []*subscriber {
+	out = append(out, t.subscribers...)
+	for _, child := range t.children {
+		out = child.all(out)
+	}
+	return out
+}
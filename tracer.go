@@ -11,11 +11,29 @@ func (n *BaseNode) SetTracer(key string) *tracer.Tracer {
 	return n.tracer
 }
 
+// InitTracer adds a tracer for the node, the messages of the tracer are published on the EventBus on
+// TracerTopic() so they can be streamed live eg; to a websocket
 func (n *BaseNode) InitTracer(t *tracer.Tracer) {
+	if n.stopTrace != nil {
+		n.stopTrace()
+	}
 	n.tracer = t
+	n.stopTrace = t.Subscribe(n.publishTrace)
 	err := n.tracer.AddTracer(n.GetUUID(), "")
 	if err != nil {
 		n.GetLogger().Errorf("error on setup node tracer err: %s", err.Error())
 		return
 	}
 }
+
+// publishTrace publishes a message of the tracer of the node
+func (n *BaseNode) publishTrace(message *tracer.Message) {
+	if n.EventBus == nil {
+		return
+	}
+	n.EventBus.Publish(TracerTopic(n.UUID, message.LoggerType), &Message{
+		NodeUUID: n.UUID,
+		NodeID:   n.ID,
+		Trace:    message,
+	})
+}
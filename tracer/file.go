@@ -0,0 +1,343 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxFileSize = 10 << 20 // 10 MB
+	DefaultMaxFiles    = 5
+)
+
+type FileStoreOptions struct {
+	MaxFileSize int64 `json:"maxFileSize"` // the size in bytes a file is rotated at
+	MaxFiles    int   `json:"maxFiles"`    // the rotated files that are kept, the oldest is deleted
+}
+
+// FileStore saves the messages as JSON lines in messages.jsonl, when the file is full it is rotated to
+// messages.1.jsonl and the older files move up to messages.<MaxFiles>.jsonl. The tracers are saved in tracers.json.
+type FileStore struct {
+	mu      sync.Mutex
+	dir     string
+	opts    FileStoreOptions
+	file    *os.File // the current file
+	size    int64
+	tracers map[string]*Tracer
+}
+
+func NewFileStore(dir string, opts *FileStoreOptions) (*FileStore, error) {
+	s := &FileStore{dir: dir, tracers: make(map[string]*Tracer)}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.MaxFileSize <= 0 {
+		s.opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if s.opts.MaxFiles <= 0 {
+		s.opts.MaxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("NewFileStore() failed to create dir %s: %w", dir, err)
+	}
+	data, err := os.ReadFile(s.tracersPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("NewFileStore() failed to read tracers: %w", err)
+	}
+	if len(data) > 0 {
+		var tracers []*Tracer
+		if err := json.Unmarshal(data, &tracers); err != nil {
+			return nil, fmt.Errorf("NewFileStore() failed to decode tracers: %w", err)
+		}
+		for _, tracer := range tracers {
+			s.tracers[tracer.UUID] = tracer
+		}
+	}
+	if err := s.open(); err != nil {
+		return nil, fmt.Errorf("NewFileStore() %w", err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) SaveTracer(tracer *Tracer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracers[tracer.UUID] = tracerInfo(tracer)
+	return s.saveTracers()
+}
+
+func (s *FileStore) Tracers() ([]*Tracer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tracers := make([]*Tracer, 0, len(s.tracers))
+	for _, tracer := range s.tracers {
+		tracers = append(tracers, tracerInfo(tracer))
+	}
+	return tracers, nil
+}
+
+// Append writes the messages in one write, none are written if one of them can not be encoded or the write fails
+func (s *FileStore) Append(messages []*Message) error {
+	data, err := encodeLines(messages)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(data)
+}
+
+func (s *FileStore) Query(query *MessageQuery) (*MessagePage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return queryMessages(messages, s.tracers, query)
+}
+
+func (s *FileStore) Trim(tracerUUID string, policy *RetentionPolicy, now time.Time) (int64, error) {
+	deleted, err := s.TrimTracers(map[string]*RetentionPolicy{tracerUUID: policy}, now)
+	return deleted[tracerUUID], err
+}
+
+// TrimTracers deletes the messages of each tracer that are over its retention policy, the files are read and
+// rewritten once for all the tracers. It returns how many messages were deleted by tracer uuid.
+func (s *FileStore) TrimTracers(policies map[string]*RetentionPolicy, now time.Time) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	kept, deleted := trimTracers(messages, policies, now)
+	if len(deleted) == 0 {
+		return deleted, nil
+	}
+	if err := s.rewrite(kept); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+func (s *FileStore) DeleteTracer(tracerUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages, err := s.read()
+	if err != nil {
+		return err
+	}
+	var kept []*Message
+	for _, message := range messages {
+		if message.TracerUUID != tracerUUID {
+			kept = append(kept, message)
+		}
+	}
+	delete(s.tracers, tracerUUID)
+	if err := s.saveTracers(); err != nil {
+		return err
+	}
+	return s.rewrite(kept)
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileStore) tracersPath() string {
+	return filepath.Join(s.dir, "tracers.json")
+}
+
+// filePath returns the path of the current file for 0, and of a rotated file for 1 to MaxFiles
+func (s *FileStore) filePath(n int) string {
+	if n == 0 {
+		return filepath.Join(s.dir, "messages.jsonl")
+	}
+	return filepath.Join(s.dir, fmt.Sprintf("messages.%d.jsonl", n))
+}
+
+func (s *FileStore) open() error {
+	file, err := os.OpenFile(s.filePath(0), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.filePath(0), err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open %s: %w", s.filePath(0), err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// write appends the lines to the current file in one write, the file is rotated before the lines if they do not
+// fit. A write that fails is cut off so the file does not keep a part of the lines.
+func (s *FileStore) write(data []byte) error {
+	if s.file == nil {
+		return errors.New("file store is closed")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if s.size > 0 && s.size+int64(len(data)) > s.opts.MaxFileSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.file.Write(data); err != nil {
+		if truncateErr := s.file.Truncate(s.size); truncateErr != nil {
+			return fmt.Errorf("error writing messages: %v, the file could not be cut off: %v", err, truncateErr)
+		}
+		return fmt.Errorf("error writing messages: %v", err)
+	}
+	s.size += int64(len(data))
+	return nil
+}
+
+func (s *FileStore) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("error rotating messages: %v", err)
+	}
+	s.file = nil
+	if err := os.Remove(s.filePath(s.opts.MaxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error rotating messages: %v", err)
+	}
+	for n := s.opts.MaxFiles - 1; n >= 0; n-- {
+		if err := os.Rename(s.filePath(n), s.filePath(n+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error rotating messages: %v", err)
+		}
+	}
+	return s.open()
+}
+
+// read returns the messages of all the files from the oldest to the newest, a line that can not be decoded eg; the
+// last line of a file that was cut off by a power loss is skipped, as is a message that is already in an older file
+// eg; when rewrite() was stopped before it deleted the rotated files
+func (s *FileStore) read() ([]*Message, error) {
+	var messages []*Message
+	seen := make(map[string]bool)
+	for n := s.opts.MaxFiles; n >= 0; n-- {
+		file, err := os.Open(s.filePath(n))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading messages: %v", err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), int(s.opts.MaxFileSize)+1)
+		for scanner.Scan() {
+			message := &Message{}
+			if err := json.Unmarshal(scanner.Bytes(), message); err == nil && !seen[message.UUID] {
+				seen[message.UUID] = true
+				messages = append(messages, message)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading messages: %v", err)
+		}
+	}
+	return messages, nil
+}
+
+// rewrite replaces all the files with the messages. The messages are written to a temp file that is synced and
+// renamed over the current file before the rotated files are deleted, so a crash keeps either the old or the new
+// messages.
+func (s *FileStore) rewrite(messages []*Message) error {
+	data, err := encodeLines(messages)
+	if err != nil {
+		return err
+	}
+	tmp := s.filePath(0) + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error rewriting messages: %v", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error rewriting messages: %v", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Rename(tmp, s.filePath(0)); err != nil {
+		os.Remove(tmp)
+		if openErr := s.open(); openErr != nil {
+			return fmt.Errorf("error rewriting messages: %v, %v", err, openErr)
+		}
+		return fmt.Errorf("error rewriting messages: %v", err)
+	}
+	syncDir(s.dir)
+	for n := 1; n <= s.opts.MaxFiles; n++ {
+		if err := os.Remove(s.filePath(n)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error deleting messages: %v", err)
+		}
+	}
+	return s.open()
+}
+
+// encodeLines encodes the messages as JSON lines
+func encodeLines(messages []*Message) ([]byte, error) {
+	var data []byte
+	for _, message := range messages {
+		line, err := json.Marshal(message)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding message %s: %v", message.UUID, err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
+
+// syncDir syncs a dir so a rename in it is kept after a power loss, it is not supported on every platform
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+func (s *FileStore) saveTracers() error {
+	tracers := make([]*Tracer, 0, len(s.tracers))
+	for _, tracer := range s.tracers {
+		tracers = append(tracers, tracer)
+	}
+	data, err := json.Marshal(tracers)
+	if err != nil {
+		return fmt.Errorf("error encoding tracers: %v", err)
+	}
+	tmp := s.tracersPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error saving tracers: %v", err)
+	}
+	if err := os.Rename(tmp, s.tracersPath()); err != nil {
+		return fmt.Errorf("error saving tracers: %v", err)
+	}
+	return nil
+}
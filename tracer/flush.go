@@ -0,0 +1,195 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ---------------------------- FLUSHER -------------------------- //
+// the flusher saves the messages in memory every interval, or sooner when a batch is full. The buffer of messages
+// is bounded while the flusher runs, when it is full a message is dropped by the overflow policy eg; when the
+// database can not be written for a long time.
+//
+//	tracer.StartFlusher(&FlushOptions{Interval: time.Second})
+//	defer tracer.Close()
+
+type OverflowPolicy string
+
+const (
+	OverflowDropOldest OverflowPolicy = "drop-oldest" // keep the newest messages
+	OverflowDropNewest OverflowPolicy = "drop-newest" // keep the oldest messages
+)
+
+const (
+	DefaultFlushInterval = 5 * time.Second
+	DefaultBatchSize     = 500
+	DefaultMaxBuffered   = 10000
+)
+
+type FlushOptions struct {
+	Interval    time.Duration  `json:"interval"`
+	BatchSize   int            `json:"batchSize"`   // the messages saved in one insert, a full batch is saved before the interval
+	MaxBuffered int            `json:"maxBuffered"` // the messages kept in memory before the overflow policy drops one
+	Overflow    OverflowPolicy `json:"overflow"`
+}
+
+func (o *FlushOptions) withDefaults() *FlushOptions {
+	out := FlushOptions{}
+	if o != nil {
+		out = *o
+	}
+	if out.Interval <= 0 {
+		out.Interval = DefaultFlushInterval
+	}
+	if out.BatchSize <= 0 {
+		out.BatchSize = DefaultBatchSize
+	}
+	if out.MaxBuffered <= 0 {
+		out.MaxBuffered = DefaultMaxBuffered
+	}
+	if out.Overflow == "" {
+		out.Overflow = OverflowDropOldest
+	}
+	return &out
+}
+
+// FlushStats are the statistics of the flusher
+type FlushStats struct {
+	Running   bool   `json:"running"`
+	Flushes   uint64 `json:"flushes"`
+	Saved     uint64 `json:"saved"`
+	Dropped   uint64 `json:"dropped"` // the messages dropped by the overflow policy
+	Errors    uint64 `json:"errors"`
+	LastError string `json:"lastError,omitempty"`
+	Buffered  int    `json:"buffered"` // the messages in memory
+}
+
+// StartFlusher saves the messages in memory in the background until Close() is called
+func (ms *Tracer) StartFlusher(opts *FlushOptions) error {
+	if ms.store == nil {
+		return fmt.Errorf("StartFlusher() %w", ErrNoStore)
+	}
+	opts = opts.withDefaults()
+	if opts.Overflow != OverflowDropOldest && opts.Overflow != OverflowDropNewest {
+		return fmt.Errorf("StartFlusher() unknown overflow policy %s", opts.Overflow)
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.flushCancel != nil {
+		return errors.New("StartFlusher() flusher has already been started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ms.flushOptions = opts
+	ms.flushNow = make(chan struct{}, 1)
+	ms.flushCancel, ms.flushDone = cancel, make(chan struct{})
+	ms.flushStats.Running = true
+	ms.trim()
+	go ms.runFlusher(ctx, opts.Interval, ms.flushNow, ms.flushDone)
+	return nil
+}
+
+func (ms *Tracer) runFlusher(ctx context.Context, interval time.Duration, flushNow, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ms.flush()
+		case <-flushNow:
+			ms.flush()
+		}
+	}
+}
+
+func (ms *Tracer) flush() error {
+	mux.Lock()
+	defer mux.Unlock()
+	saved, err := ms.saveMessages()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.flushStats.Flushes++
+	if err != nil {
+		ms.flushStats.Errors++
+		ms.flushStats.LastError = err.Error()
+		return err
+	}
+	ms.flushStats.Saved += uint64(saved)
+	return nil
+}
+
+// Close stops the flusher and saves the messages that are still in memory
+func (ms *Tracer) Close() error {
+	ms.mu.Lock()
+	cancel, done := ms.flushCancel, ms.flushDone
+	ms.flushCancel, ms.flushDone = nil, nil
+	ms.flushStats.Running = false
+	pending := len(ms.unsavedMessages)
+	ms.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	if pending == 0 || ms.store == nil {
+		return nil
+	}
+	return ms.flush()
+}
+
+// GetFlushStats returns the statistics of the flusher
+func (ms *Tracer) GetFlushStats() *FlushStats {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	stats := ms.flushStats
+	stats.Buffered = len(ms.unsavedMessages)
+	return &stats
+}
+
+// buffer keeps a message in memory until it is saved, a full batch wakes up the flusher
+func (ms *Tracer) buffer(message *Message) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	opts := ms.flushOptions
+	if opts != nil && len(ms.unsavedMessages) >= opts.MaxBuffered && opts.Overflow == OverflowDropNewest {
+		ms.flushStats.Dropped++
+		return
+	}
+	ms.unsavedMessages = append(ms.unsavedMessages, message)
+	if opts == nil {
+		return
+	}
+	ms.trim()
+	if len(ms.unsavedMessages) >= opts.BatchSize {
+		select {
+		case ms.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// requeue puts back the messages that failed to save in front of the messages added since
+func (ms *Tracer) requeue(messages []*Message) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.unsavedMessages = append(append([]*Message{}, messages...), ms.unsavedMessages...)
+	ms.trim()
+}
+
+// trim drops the messages over the max buffered by the overflow policy, ms.mu must be held
+func (ms *Tracer) trim() {
+	opts := ms.flushOptions
+	if opts == nil || len(ms.unsavedMessages) <= opts.MaxBuffered {
+		return
+	}
+	over := len(ms.unsavedMessages) - opts.MaxBuffered
+	ms.flushStats.Dropped += uint64(over)
+	if opts.Overflow == OverflowDropNewest {
+		ms.unsavedMessages = ms.unsavedMessages[:opts.MaxBuffered]
+		return
+	}
+	ms.unsavedMessages = ms.unsavedMessages[over:]
+}
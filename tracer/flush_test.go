@@ -0,0 +1,90 @@
+package tracer
+
+import (
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlusher(t *testing.T) {
+	db, err := InitDatabase(filepath.Join(t.TempDir(), "rx.db"), &Tracer{}, &Message{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	tracer := NewTracer("modbus", "modbus-driver", logger, db)
+	if err := tracer.AddTracer("node-a", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.StartFlusher(&FlushOptions{Interval: time.Hour, BatchSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.StartFlusher(nil); err == nil {
+		t.Fatal("expected an error when the flusher is already started")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				tracer.Debugf("writer %d message %d", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// a full batch is saved without waiting for the interval
+	deadline := time.Now().Add(time.Second)
+	for tracer.GetFlushStats().Saved == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if tracer.GetFlushStats().Saved == 0 {
+		t.Fatal("expected a full batch to be saved")
+	}
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&Message{}).Where("tracer_uuid = ?", tracer.UUID).Count(&count)
+	stats := tracer.GetFlushStats()
+	if count != 100 || stats.Saved != 100 || stats.Buffered != 0 || stats.Running {
+		t.Fatalf("expected all the messages to be saved on close, saved %d stats %+v", count, stats)
+	}
+}
+
+func TestFlusherOverflow(t *testing.T) {
+	db, err := InitDatabase(filepath.Join(t.TempDir(), "rx.db"), &Tracer{}, &Message{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	for _, overflow := range []OverflowPolicy{OverflowDropOldest, OverflowDropNewest} {
+		tracer := NewTracer("modbus", "modbus-driver", logger, db)
+		if err := tracer.StartFlusher(&FlushOptions{Interval: time.Hour, MaxBuffered: 3, Overflow: overflow}); err != nil {
+			t.Fatal(err)
+		}
+		for _, text := range []string{"a", "b", "c", "d", "e"} {
+			tracer.Debugf("%s", text)
+		}
+		messages := tracer.GetInMemoryMessages()
+		first := "c"
+		if overflow == OverflowDropNewest {
+			first = "a"
+		}
+		if len(messages) != 3 || messages[0].Text != first || tracer.GetFlushStats().Dropped != 2 {
+			t.Fatalf("%s: unexpected messages %d first %s stats %+v", overflow, len(messages), messages[0].Text, tracer.GetFlushStats())
+		}
+		// the tracer was not added so the messages can not be saved
+		if err := tracer.Close(); err == nil {
+			t.Fatalf("%s: expected an error saving the messages of a tracer with no uuid", overflow)
+		}
+	}
+	tracer := NewTracer("modbus", "modbus-driver", logger, db)
+	if err := tracer.StartFlusher(&FlushOptions{Overflow: "drop-all"}); err == nil {
+		t.Fatal("expected an error for an unknown overflow policy")
+	}
+}
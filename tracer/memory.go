@@ -0,0 +1,129 @@
+package tracer
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMemoryStoreSize is the number of messages a MemoryStore keeps when no size is set
+const DefaultMemoryStoreSize = 10000
+
+// MemoryStore keeps the newest messages of all the tracers in a ring buffer, the oldest message is dropped when it
+// is full. It is for tests and small devices that do not need the messages after a restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	tracers  map[string]*Tracer
+	messages []*Message // the ring buffer
+	next     int        // where the next message is written
+	full     bool
+}
+
+func NewMemoryStore(size int) *MemoryStore {
+	if size <= 0 {
+		size = DefaultMemoryStoreSize
+	}
+	return &MemoryStore{
+		tracers:  make(map[string]*Tracer),
+		messages: make([]*Message, size),
+	}
+}
+
+func (s *MemoryStore) SaveTracer(tracer *Tracer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracers[tracer.UUID] = tracerInfo(tracer)
+	return nil
+}
+
+func (s *MemoryStore) Tracers() ([]*Tracer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tracers := make([]*Tracer, 0, len(s.tracers))
+	for _, tracer := range s.tracers {
+		tracers = append(tracers, tracerInfo(tracer))
+	}
+	return tracers, nil
+}
+
+func (s *MemoryStore) Append(messages []*Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, message := range messages {
+		saved := *message
+		s.messages[s.next] = &saved
+		s.next = (s.next + 1) % len(s.messages)
+		if s.next == 0 {
+			s.full = true
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Query(query *MessageQuery) (*MessagePage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page, err := queryMessages(s.all(), s.tracers, query)
+	if err != nil {
+		return nil, err
+	}
+	for i, message := range page.Messages {
+		out := *message
+		page.Messages[i] = &out
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) Trim(tracerUUID string, policy *RetentionPolicy, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept, deleted := trimMessages(s.all(), tracerUUID, policy, now)
+	if deleted > 0 {
+		s.reset(kept)
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) DeleteTracer(tracerUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tracers, tracerUUID)
+	var kept []*Message
+	for _, message := range s.all() {
+		if message.TracerUUID != tracerUUID {
+			kept = append(kept, message)
+		}
+	}
+	s.reset(kept)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// all returns the messages from the oldest to the newest
+func (s *MemoryStore) all() []*Message {
+	if !s.full {
+		return append([]*Message{}, s.messages[:s.next]...)
+	}
+	return append(append([]*Message{}, s.messages[s.next:]...), s.messages[:s.next]...)
+}
+
+// reset fills the ring buffer with the messages, there are never more messages than the size of the buffer
+func (s *MemoryStore) reset(messages []*Message) {
+	size := len(s.messages)
+	s.messages = make([]*Message, size)
+	s.next = copy(s.messages, messages) % size
+	s.full = len(messages) == size
+}
+
+// tracerInfo returns a tracer without its messages
+func tracerInfo(tracer *Tracer) *Tracer {
+	return &Tracer{
+		UUID:         tracer.UUID,
+		Path:         tracer.Path,
+		Application:  tracer.Application,
+		Key:          tracer.Key,
+		InstanceUUID: tracer.InstanceUUID,
+	}
+}
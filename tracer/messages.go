@@ -47,16 +47,21 @@ func (ms *Tracer) AddMessage(path, text, loggerType string, addToDisk bool) (*Me
 		ms.logger.Warning(logMessage)
 	}
 
-	ms.unsavedMessages = append(ms.unsavedMessages, newMessage)
+	ms.buffer(newMessage)
+	ms.notify(newMessage)
 
 	return newMessage, nil
 }
 
 func (ms *Tracer) GetInMemoryMessages() []*Message {
-	return ms.unsavedMessages
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]*Message{}, ms.unsavedMessages...)
 }
 
 func (ms *Tracer) getInMemoryMessagesNoDisk() []*Message {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	var unsavedMessages []*Message
 	for _, message := range ms.unsavedMessages {
 		if message.AddToDisk {
@@ -79,22 +84,21 @@ func (ms *Tracer) getAllMessagesByTracer(tracerUUID string) ([]*Message, error)
 }
 
 func (ms *Tracer) GetAllMessages() ([]*Message, error) {
-	if ms.db == nil {
-		return nil, errors.New("GetAllMessages() database has not been initialised yet")
+	if ms.store == nil {
+		return nil, fmt.Errorf("GetAllMessages() %w", ErrNoStore)
 	}
 
-	var messagesFromDB []*Message
-
 	// Retrieve messages from the database
-	if err := ms.db.Find(&messagesFromDB).Error; err != nil {
+	messagesFromDB, err := ms.allMessages(&MessageQuery{})
+	if err != nil {
 		return nil, fmt.Errorf("error retrieving messages from the database: %v", err)
 	}
 	return messagesFromDB, nil
 }
 
 func (ms *Tracer) GetAllMessagesCombine(byTracerUUIID string) ([]*Message, error) {
-	if ms.db == nil {
-		return nil, errors.New("GetAllMessages() database has not been initialised yet")
+	if ms.store == nil {
+		return nil, fmt.Errorf("GetAllMessages() %w", ErrNoStore)
 	}
 
 	// Retrieve messages from the database
@@ -110,139 +114,84 @@ func (ms *Tracer) GetAllMessagesCombine(byTracerUUIID string) ([]*Message, error
 	return allMessages, nil
 }
 
-// SaveMessagesToDB saves unsaved messages to the database.
-func (ms *Tracer) SaveMessagesToDB(maxTableSize int) error {
-	mux.Lock()
-	defer mux.Unlock()
-
-	if ms.UUID == "" {
-		return errors.New("SaveMessagesToDB() tracer-uuid can not be empty")
-	}
-	if ms.db == nil {
-		return errors.New("SaveMessagesToDB() database has not been initialised yet")
-	}
-
-	// Combine messages from memory and the database
-	allMessages, err := ms.GetAllMessagesCombine(ms.UUID)
-	if err != nil {
-		return err
+// GetAllMessagesByInstance returns the saved messages of all the tracers of an instance eg; a node, as a node gets
+// a new tracer each time it is started, and the messages in memory if this is the tracer of the instance
+func (ms *Tracer) GetAllMessagesByInstance(instanceUUID string) ([]*Message, error) {
+	if ms.store == nil {
+		return nil, fmt.Errorf("GetAllMessagesByInstance() %w", ErrNoStore)
 	}
-	allMessagesDisk, err := ms.GetMessagesByTracerUUID(ms.UUID)
+	messages, err := ms.allMessages(&MessageQuery{InstanceUUID: instanceUUID})
 	if err != nil {
-		return err
-	}
-
-	ms.DebugfNotify("tracer messages on disk: %d messages in memory: %d & max table size: %d", len(allMessagesDisk), len(ms.getInMemoryMessagesNoDisk()), maxTableSize)
-
-	// Check if the number of messages exceeds the maximum table size
-	if len(allMessages) > maxTableSize {
-		numToRemove := len(allMessages) - maxTableSize
-
-		// Limit numToRemove to the available messages
-		if numToRemove > len(ms.unsavedMessages) {
-			numToRemove = len(ms.unsavedMessages)
-		}
-
-		// Calculate the timestamp threshold for retaining messages
-		thresholdTime := time.Now().Add(-time.Duration(maxTableSize) * time.Second)
-
-		// Remove the oldest messages from memory based on their timestamp
-		for i := 0; i < numToRemove; i++ {
-			if allMessages[i].Timestamp.Before(thresholdTime) {
-				numToRemove--
-			} else {
-				break
-			}
-		}
-
-		// Construct a slice of UUIDs for the oldest messages to be deleted
-		oldestMessageUUIDs := make([]string, numToRemove)
-		for i := 0; i < numToRemove; i++ {
-			oldestMessageUUIDs[i] = allMessages[i].UUID
-		}
-		ms.DebugfNotify("tracer messages to delete from DB count: %d", len(oldestMessageUUIDs))
-
-		// Bulk delete the oldest messages from the database based on their UUIDs
-		if err := ms.db.Where("uuid IN ?", oldestMessageUUIDs).Delete(&Message{}).Error; err != nil {
-			return fmt.Errorf("error bulk deleting oldest messages from the database: %v", err)
-		}
-
-		// Remove the deleted messages from the slice
-		ms.unsavedMessages = ms.unsavedMessages[numToRemove:]
+		return nil, fmt.Errorf("error retrieving messages of instance %s: %v", instanceUUID, err)
 	}
-
-	ms.DebugfNotify("tracer messages to add to DB count: %d", len(ms.unsavedMessages))
-	if len(ms.unsavedMessages) > 0 {
-		// Bulk save unsaved messages to the database and associate them with the specified tracer
-		for _, msg := range ms.unsavedMessages {
-			msg.TracerUUID = ms.UUID
-		}
-		if err := ms.db.Create(&ms.unsavedMessages).Error; err != nil {
-			return fmt.Errorf("error bulk saving unsaved messages to the database: %v", err)
-		}
+	if ms.InstanceUUID == instanceUUID {
+		messages = append(messages, ms.getInMemoryMessagesNoDisk()...)
 	}
+	return messages, nil
+}
 
-	// Clear unsaved messages in memory
-	ms.unsavedMessages = []*Message{}
-
-	return nil
+// SaveMessages saves the messages in memory to the database, old messages are removed by a Compactor
+func (ms *Tracer) SaveMessages() error {
+	mux.Lock()
+	defer mux.Unlock()
+	_, err := ms.saveMessages()
+	return err
 }
 
-func (ms *Tracer) saveMessagesNonBatch(maxTableSize int) error {
+// SaveMessagesToDB saves the messages in memory to the database and keeps the newest maxTableSize messages of the
+// tracer.
+//
+// Deprecated: use SaveMessages() and a Compactor with a RetentionPolicy
+func (ms *Tracer) SaveMessagesToDB(maxTableSize int) error {
 	mux.Lock()
 	defer mux.Unlock()
+	if _, err := ms.saveMessages(); err != nil {
+		return err
+	}
+	_, err := ms.store.Trim(ms.UUID, &RetentionPolicy{Retention: Retention{MaxCount: maxTableSize}}, time.Now())
+	return err
+}
 
+// saveMessages saves the messages in memory and returns how many were saved, mux must be held
+func (ms *Tracer) saveMessages() (int, error) {
 	if ms.UUID == "" {
-		return errors.New("SaveMessagesToDB() tracer-uuid can not be empty")
+		return 0, errors.New("SaveMessages() tracer-uuid can not be empty")
 	}
-	if ms.db == nil {
-		return errors.New("SaveMessagesToDB() database has not been initialised yet")
+	if ms.store == nil {
+		return 0, fmt.Errorf("SaveMessages() %w", ErrNoStore)
 	}
-
-	// Combine messages from memory and the database
-	allMessages, err := ms.GetAllMessages()
-	if err != nil {
-		return err
+	ms.mu.Lock()
+	batch := ms.unsavedMessages
+	ms.unsavedMessages = []*Message{}
+	batchSize := DefaultBatchSize
+	if ms.flushOptions != nil {
+		batchSize = ms.flushOptions.BatchSize
 	}
-
-	// Check if the number of messages exceeds the maximum table size
-	if len(allMessages) > maxTableSize {
-		numToRemove := len(allMessages) - maxTableSize
-
-		// Limit numToRemove to the available messages
-		if numToRemove > len(ms.unsavedMessages) {
-			numToRemove = len(ms.unsavedMessages)
-		}
-
-		// Delete the oldest messages from the database
-		for i := 0; i < numToRemove; i++ {
-			if err := ms.db.Delete(allMessages[i]).Error; err != nil {
-				return fmt.Errorf("error deleting oldest message from the database: %v", err)
-			}
-		}
-
-		// Remove the deleted messages from the slice
-		ms.unsavedMessages = ms.unsavedMessages[numToRemove:]
+	ms.mu.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
 	}
-
-	// Save unsaved messages to the database and associate them with the specified tracer
-	for _, msg := range ms.unsavedMessages {
+	// Bulk save unsaved messages to the database and associate them with the specified tracer
+	for _, msg := range batch {
 		msg.TracerUUID = ms.UUID
-		if err := ms.db.Create(msg).Error; err != nil {
-			return fmt.Errorf("error saving unsaved message to the database: %v", err)
+	}
+	// each batch is saved all or none, so only the batches that failed are put back
+	for saved := 0; saved < len(batch); saved += batchSize {
+		if err := ms.store.Append(batch[saved:min(saved+batchSize, len(batch))]); err != nil {
+			ms.requeue(batch[saved:])
+			return saved, fmt.Errorf("error bulk saving unsaved messages to the database: %v", err)
 		}
 	}
-
-	// Clear unsaved messages in memory
-	ms.unsavedMessages = []*Message{}
-
-	return nil
+	return len(batch), nil
 }
 
 // GetTracerMessages retrieves all messages associated with a tracer from the database.
 func (ms *Tracer) GetTracerMessages(uuid string) ([]*Message, error) {
-	var messages []*Message
-	if err := ms.db.Where("tracer_uuid = ?", uuid).Find(&messages).Error; err != nil {
+	if ms.store == nil {
+		return nil, fmt.Errorf("GetTracerMessages() %w", ErrNoStore)
+	}
+	messages, err := ms.allMessages(&MessageQuery{TracerUUID: uuid})
+	if err != nil {
 		return nil, fmt.Errorf("error retrieving messages for tracer: %v", err)
 	}
 	return messages, nil
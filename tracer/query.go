@@ -0,0 +1,96 @@
+package tracer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ErrInvalidCursor is returned when the cursor of a query was not returned by QueryMessages()
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MessageQuery filters the saved messages, an empty field does not filter
+type MessageQuery struct {
+	TracerUUID   string    `json:"tracerUUID,omitempty"`
+	InstanceUUID string    `json:"instanceUUID,omitempty"` // the node uuid of the tracer
+	Path         string    `json:"path,omitempty"`
+	Key          string    `json:"key,omitempty"` // the key of the tracer
+	LoggerTypes  []string  `json:"types,omitempty"`
+	Search       string    `json:"search,omitempty"` // the text contains this, not case-sensitive
+	From         time.Time `json:"from,omitempty"`   // from this time on
+	To           time.Time `json:"to,omitempty"`     // before this time
+	Order        string    `json:"order,omitempty"`  // OrderDesc (newest first) or OrderAsc, the default is OrderDesc
+	Cursor       string    `json:"cursor,omitempty"` // the NextCursor of the previous page
+	Limit        int       `json:"limit,omitempty"`  // the default is DefaultQueryLimit
+}
+
+// MessagePage is one page of a query, NextCursor is empty on the last page
+type MessagePage struct {
+	Messages   []*Message `json:"messages"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// QueryMessages returns one page of the saved messages that match the query, ordered by timestamp. The messages that
+// are still in memory are not returned until they are saved with SaveMessages().
+func (ms *Tracer) QueryMessages(query *MessageQuery) (*MessagePage, error) {
+	if ms.store == nil {
+		return nil, fmt.Errorf("QueryMessages() %w", ErrNoStore)
+	}
+	page, err := ms.store.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("QueryMessages() %w", err)
+	}
+	return page, nil
+}
+
+// allMessages returns all the pages of the query from the oldest to the newest message
+func (ms *Tracer) allMessages(query *MessageQuery) ([]*Message, error) {
+	query.Order, query.Limit = OrderAsc, MaxQueryLimit
+	var messages []*Message
+	for {
+		page, err := ms.store.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, page.Messages...)
+		if page.NextCursor == "" {
+			return messages, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// the cursor is the timestamp and uuid of the last message of a page
+func encodeCursor(timestamp time.Time, uuid string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(timestamp.Local().Format(time.RFC3339Nano) + "|" + uuid))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	timestamp, uuid, ok := strings.Cut(string(data), "|")
+	if !ok || uuid == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return t.Local(), uuid, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
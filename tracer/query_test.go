@@ -0,0 +1,73 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueryMessages(t *testing.T) {
+	db, err := InitDatabase(filepath.Join(t.TempDir(), "rx.db"), &Tracer{}, &Message{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modbus := NewTracer("modbus", "modbus-driver", logrus.New(), db)
+	if err := modbus.AddTracer("node-a", "read-coil"); err != nil {
+		t.Fatal(err)
+	}
+	bacnet := NewTracer("bacnet", "bacnet-server", logrus.New(), db)
+	if err := bacnet.AddTracer("node-b", "write"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		modbus.Infof("read %d", i)
+		modbus.Errorf("Timeout on read %d", i)
+		bacnet.Errorf("write %d failed", i)
+	}
+	for _, tracer := range []*Tracer{modbus, bacnet} {
+		if err := tracer.SaveMessages(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := modbus.QueryMessages(&MessageQuery{InstanceUUID: "node-a", LoggerTypes: []string{errorType}, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for page != nil {
+		for _, message := range page.Messages {
+			texts = append(texts, message.Text)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if page, err = modbus.QueryMessages(&MessageQuery{InstanceUUID: "node-a", LoggerTypes: []string{errorType}, Limit: 2, Cursor: page.NextCursor}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(texts) != "[Timeout on read 4 Timeout on read 3 Timeout on read 2 Timeout on read 1 Timeout on read 0]" {
+		t.Fatalf("unexpected messages %v", texts)
+	}
+
+	page, err = modbus.QueryMessages(&MessageQuery{Key: "write", Search: "3 FAIL", Order: OrderAsc, From: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].Text != "write 3 failed" || page.NextCursor != "" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	if page, err = modbus.QueryMessages(&MessageQuery{TracerUUID: modbus.UUID, Path: "modbus", To: start}); err != nil || len(page.Messages) != 0 {
+		t.Fatalf("expected no messages before the start got %+v err: %v", page, err)
+	}
+	if _, err := modbus.QueryMessages(&MessageQuery{Cursor: "nope"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected an invalid cursor error got %v", err)
+	}
+	if _, err := modbus.QueryMessages(&MessageQuery{Order: "up"}); err == nil {
+		t.Fatal("expected an error for an unknown order")
+	}
+}
@@ -0,0 +1,219 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------- RETENTION -------------------------- //
+// a Compactor removes the old messages in the background, each tracer keeps its messages by its RetentionPolicy
+// or by the default policy of the compactor eg; keep the last 1000 messages for a day, and errors for a week
+//
+//	compactor, err := NewCompactor(tracer.GetStore(), &RetentionPolicy{
+//		Retention: Retention{MaxCount: 1000, MaxAge: 24 * time.Hour},
+//		Levels:    map[string]*Retention{"error": {MaxAge: 7 * 24 * time.Hour}},
+//	})
+//	compactor.Start(time.Minute)
+
+// Retention is how long messages are kept, a message is deleted when it is over either limit
+type Retention struct {
+	MaxCount int           `json:"maxCount,omitempty"` // keep the newest messages, 0 keeps any number
+	MaxAge   time.Duration `json:"maxAge,omitempty"`   // delete messages older than this, 0 keeps them at any age
+}
+
+func (r *Retention) empty() bool {
+	return r == nil || (r.MaxCount <= 0 && r.MaxAge <= 0)
+}
+
+// RetentionPolicy is the retention of the messages of a tracer, a logger type in Levels is kept by its own
+// retention and the other logger types are kept by Retention
+type RetentionPolicy struct {
+	Retention
+	Levels map[string]*Retention `json:"levels,omitempty"` // by logger type eg; "error"
+}
+
+// CompactionResult is the result of one compaction
+type CompactionResult struct {
+	Start    time.Time        `json:"start"`
+	Duration time.Duration    `json:"duration"`
+	Deleted  int64            `json:"deleted"`
+	ByTracer map[string]int64 `json:"byTracer,omitempty"` // the deleted messages by tracer uuid
+}
+
+// CompactionStats are the statistics of all the compactions since the compactor was created
+type CompactionStats struct {
+	Running   bool              `json:"running"`
+	Runs      uint64            `json:"runs"`
+	Deleted   int64             `json:"deleted"`
+	Errors    uint64            `json:"errors"`
+	LastError string            `json:"lastError,omitempty"`
+	Last      *CompactionResult `json:"last,omitempty"`
+}
+
+// tracersTrimmer is a Store that trims the messages of all the tracers in one pass, eg; the FileStore that has to read
+// and rewrite all its files for a trim
+type tracersTrimmer interface {
+	TrimTracers(policies map[string]*RetentionPolicy, now time.Time) (map[string]int64, error)
+}
+
+type Compactor struct {
+	store    Store
+	mu       sync.Mutex
+	policy   *RetentionPolicy            // the default policy
+	policies map[string]*RetentionPolicy // by tracer uuid
+	stats    CompactionStats
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewCompactor returns a compactor with a default policy for the tracers that have no policy of their own, a nil
+// policy keeps the messages of those tracers
+func NewCompactor(store Store, policy *RetentionPolicy) (*Compactor, error) {
+	if store == nil {
+		return nil, errors.New("NewCompactor() store can not be empty")
+	}
+	return &Compactor{
+		store:    store,
+		policy:   policy,
+		policies: make(map[string]*RetentionPolicy),
+	}, nil
+}
+
+// SetPolicy sets the policy of a tracer, a nil policy uses the default policy again
+func (c *Compactor) SetPolicy(tracerUUID string, policy *RetentionPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if policy == nil {
+		delete(c.policies, tracerUUID)
+		return
+	}
+	c.policies[tracerUUID] = policy
+}
+
+// SetDefaultPolicy sets the policy of the tracers that have no policy of their own
+func (c *Compactor) SetDefaultPolicy(policy *RetentionPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+}
+
+// Start compacts the messages every interval until Stop() is called
+func (c *Compactor) Start(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("Start() interval must be more than 0")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return errors.New("Start() compactor has already been started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel, c.done = cancel, make(chan struct{})
+	c.stats.Running = true
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Compact()
+			}
+		}
+	}(c.done)
+	return nil
+}
+
+// Stop stops the compactor and waits for the compaction that is running to finish
+func (c *Compactor) Stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel, c.done = nil, nil
+	c.stats.Running = false
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Compact deletes the messages of each tracer that are over its retention
+func (c *Compactor) Compact() (*CompactionResult, error) {
+	result := &CompactionResult{Start: time.Now(), ByTracer: map[string]int64{}}
+	err := c.compact(result)
+	result.Duration = time.Since(result.Start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Runs++
+	c.stats.Deleted += result.Deleted
+	c.stats.Last = result
+	if err != nil {
+		c.stats.Errors++
+		c.stats.LastError = err.Error()
+	}
+	return result, err
+}
+
+func (c *Compactor) compact(result *CompactionResult) error {
+	tracers, err := c.store.Tracers()
+	if err != nil {
+		return fmt.Errorf("Compact() error retrieving tracers: %v", err)
+	}
+	sort.Slice(tracers, func(i, j int) bool { return tracers[i].UUID < tracers[j].UUID })
+	mux.Lock()
+	defer mux.Unlock()
+	if trimmer, ok := c.store.(tracersTrimmer); ok {
+		policies := make(map[string]*RetentionPolicy, len(tracers))
+		for _, tracer := range tracers {
+			policies[tracer.UUID] = c.policyOf(tracer.UUID)
+		}
+		deleted, err := trimmer.TrimTracers(policies, result.Start)
+		for tracerUUID, n := range deleted {
+			result.ByTracer[tracerUUID] = n
+			result.Deleted += n
+		}
+		return err
+	}
+	var errs []error
+	for _, tracer := range tracers {
+		tracerUUID := tracer.UUID
+		deleted, err := c.store.Trim(tracerUUID, c.policyOf(tracerUUID), result.Start)
+		if deleted > 0 {
+			result.ByTracer[tracerUUID] = deleted
+			result.Deleted += deleted
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Compactor) policyOf(tracerUUID string) *RetentionPolicy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if policy, ok := c.policies[tracerUUID]; ok {
+		return policy
+	}
+	return c.policy
+}
+
+// GetStats returns the statistics of the compactions
+func (c *Compactor) GetStats() *CompactionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	if stats.Last != nil {
+		last := *stats.Last
+		stats.Last = &last
+	}
+	return &stats
+}
@@ -0,0 +1,121 @@
+package tracer
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompactor(t *testing.T) {
+	db, err := InitDatabase(filepath.Join(t.TempDir(), "rx.db"), &Tracer{}, &Message{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modbus := NewTracer("modbus", "modbus-driver", logrus.New(), db)
+	if err := modbus.AddTracer("node-a", ""); err != nil {
+		t.Fatal(err)
+	}
+	bacnet := NewTracer("bacnet", "bacnet-server", logrus.New(), db)
+	if err := bacnet.AddTracer("node-b", ""); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		modbus.Debugf("read %d", i)
+		modbus.Errorf("error %d", i)
+		bacnet.Debugf("write %d", i)
+	}
+	old, _ := bacnet.AddMessage("bacnet", "old", debug, true)
+	old.Timestamp = time.Now().Add(-2 * time.Hour)
+	for _, tracer := range []*Tracer{modbus, bacnet} {
+		if err := tracer.SaveMessages(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compactor, err := NewCompactor(NewSQLiteStore(db), &RetentionPolicy{Retention: Retention{MaxAge: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactor.SetPolicy(modbus.UUID, &RetentionPolicy{
+		Retention: Retention{MaxCount: 3},
+		Levels:    map[string]*Retention{errorType: {MaxCount: 5}},
+	})
+	result, err := compactor.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deleted != 13 || result.ByTracer[modbus.UUID] != 12 || result.ByTracer[bacnet.UUID] != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	count := func(tracerUUID, loggerType string) (n int64) {
+		db.Model(&Message{}).Where("tracer_uuid = ? AND logger_type = ?", tracerUUID, loggerType).Count(&n)
+		return n
+	}
+	if count(modbus.UUID, debug) != 3 || count(modbus.UUID, errorType) != 5 || count(bacnet.UUID, debug) != 10 {
+		t.Fatalf("unexpected messages left %d %d %d", count(modbus.UUID, debug), count(modbus.UUID, errorType), count(bacnet.UUID, debug))
+	}
+	page, err := modbus.QueryMessages(&MessageQuery{TracerUUID: modbus.UUID, LoggerTypes: []string{debug}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 3 || page.Messages[0].Text != "read 9" || page.Messages[2].Text != "read 7" {
+		t.Fatalf("expected the newest messages to be kept %+v", page.Messages)
+	}
+
+	if err := compactor.Start(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := compactor.Start(10 * time.Millisecond); err == nil {
+		t.Fatal("expected an error when the compactor is already started")
+	}
+	time.Sleep(50 * time.Millisecond)
+	compactor.Stop()
+	if stats := compactor.GetStats(); stats.Running || stats.Runs < 2 || stats.Deleted != 13 || stats.Errors != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCompactorFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir(), &FileStoreOptions{MaxFileSize: 400, MaxFiles: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	now := time.Now()
+	for _, tracerUUID := range []string{"t1", "t2", "t3"} {
+		if err := store.SaveTracer(&Tracer{UUID: tracerUUID}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			message := &Message{UUID: fmt.Sprintf("%s-%d", tracerUUID, i), TracerUUID: tracerUUID, Text: "x", Timestamp: now.Add(time.Duration(i) * time.Second)}
+			if err := store.Append([]*Message{message}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the tracers share the default policy, each tracer keeps its own newest messages
+	compactor, err := NewCompactor(store, &RetentionPolicy{Retention: Retention{MaxCount: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactor.SetPolicy("t3", &RetentionPolicy{})
+	result, err := compactor.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deleted != 6 || result.ByTracer["t1"] != 3 || result.ByTracer["t2"] != 3 || result.ByTracer["t3"] != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for tracerUUID, count := range map[string]int{"t1": 2, "t2": 2, "t3": 5} {
+		page, err := store.Query(&MessageQuery{TracerUUID: tracerUUID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Messages) != count || page.Messages[0].UUID != tracerUUID+"-4" {
+			t.Fatalf("expected the %d newest messages of %s got %+v", count, tracerUUID, page.Messages)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package tracer
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// SQLiteStore saves the tracers and messages with gorm, the tables are migrated by InitDatabase()
+type SQLiteStore struct {
+	db *gorm.DB
+}
+
+func NewSQLiteStore(db *gorm.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+func (s *SQLiteStore) DB() *gorm.DB {
+	return s.db
+}
+
+func (s *SQLiteStore) SaveTracer(tracer *Tracer) error {
+	if err := s.db.Save(tracer).Error; err != nil {
+		return fmt.Errorf("error saving tracer: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Tracers() ([]*Tracer, error) {
+	var tracers []*Tracer
+	if err := s.db.Find(&tracers).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving tracers: %v", err)
+	}
+	return tracers, nil
+}
+
+// Append saves the messages in batches of DefaultBatchSize in one transaction
+func (s *SQLiteStore) Append(messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if err := s.db.CreateInBatches(messages, DefaultBatchSize).Error; err != nil {
+		return fmt.Errorf("error bulk saving unsaved messages to the database: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Query(query *MessageQuery) (*MessagePage, error) {
+	if query == nil {
+		query = &MessageQuery{}
+	}
+	limit, order, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+	tx := s.db.Model(&Message{})
+	if query.TracerUUID != "" {
+		tx = tx.Where("tracer_uuid = ?", query.TracerUUID)
+	}
+	if query.InstanceUUID != "" || query.Key != "" {
+		tracers := s.db.Model(&Tracer{}).Select("uuid").Where(&Tracer{InstanceUUID: query.InstanceUUID, Key: query.Key})
+		tx = tx.Where("tracer_uuid IN (?)", tracers)
+	}
+	if query.Path != "" {
+		tx = tx.Where("path = ?", query.Path)
+	}
+	if len(query.LoggerTypes) > 0 {
+		tx = tx.Where("logger_type IN ?", query.LoggerTypes)
+	}
+	if query.Search != "" {
+		tx = tx.Where("LOWER(text) LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(query.Search))+"%")
+	}
+	// timestamps are saved in local time, so the times are compared in local time as well
+	if !query.From.IsZero() {
+		tx = tx.Where("timestamp >= ?", query.From.Local())
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("timestamp < ?", query.To.Local())
+	}
+	if after != nil {
+		compare := "<"
+		if order == OrderAsc {
+			compare = ">"
+		}
+		timestamp := after.Timestamp.Local()
+		tx = tx.Where(fmt.Sprintf("(timestamp %s ? OR (timestamp = ? AND uuid %s ?))", compare, compare), timestamp, timestamp, after.UUID)
+	}
+
+	var messages []*Message
+	// one more message than the limit is read to know if there is a next page
+	if err := tx.Order("timestamp " + order).Order("uuid " + order).Limit(limit + 1).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving messages: %v", err)
+	}
+	return newPage(messages, limit), nil
+}
+
+func (s *SQLiteStore) Trim(tracerUUID string, policy *RetentionPolicy, now time.Time) (int64, error) {
+	if policy == nil {
+		return 0, nil
+	}
+	var deleted int64
+	levels := make([]string, 0, len(policy.Levels))
+	for level, retention := range policy.Levels {
+		levels = append(levels, level)
+		n, err := s.trim(tracerUUID, retention, now, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("logger_type = ?", level)
+		})
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	n, err := s.trim(tracerUUID, &policy.Retention, now, func(tx *gorm.DB) *gorm.DB {
+		if len(levels) == 0 {
+			return tx
+		}
+		return tx.Where("logger_type NOT IN ?", levels)
+	})
+	return deleted + n, err
+}
+
+// trim deletes the messages of a tracer in the scope that are older than the max age or that are not in the newest
+// max count messages
+func (s *SQLiteStore) trim(tracerUUID string, retention *Retention, now time.Time, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	if retention.empty() {
+		return 0, nil
+	}
+	messages := func() *gorm.DB {
+		return s.db.Model(&Message{}).Where("tracer_uuid = ?", tracerUUID).Scopes(scope)
+	}
+	var deleted int64
+	if retention.MaxAge > 0 {
+		tx := messages().Where("timestamp < ?", now.Add(-retention.MaxAge).Local()).Delete(&Message{})
+		if tx.Error != nil {
+			return 0, fmt.Errorf("error deleting old messages of tracer %s: %v", tracerUUID, tx.Error)
+		}
+		deleted += tx.RowsAffected
+	}
+	if retention.MaxCount > 0 {
+		newest := messages().Select("uuid").Order("timestamp desc").Order("uuid desc").Limit(retention.MaxCount)
+		tx := messages().Where("uuid NOT IN (?)", newest).Delete(&Message{})
+		if tx.Error != nil {
+			return deleted, fmt.Errorf("error deleting messages over the max count of tracer %s: %v", tracerUUID, tx.Error)
+		}
+		deleted += tx.RowsAffected
+	}
+	return deleted, nil
+}
+
+func (s *SQLiteStore) DeleteTracer(tracerUUID string) error {
+	if err := s.db.Where("tracer_uuid = ?", tracerUUID).Delete(&Message{}).Error; err != nil {
+		return fmt.Errorf("error deleting messages of tracer: %v", err)
+	}
+	if err := s.db.Where("uuid = ?", tracerUUID).Delete(&Tracer{}).Error; err != nil {
+		return fmt.Errorf("error deleting tracer: %v", err)
+	}
+	return nil
+}
+
+// Close does not close the database, it can be shared with other models
+func (s *SQLiteStore) Close() error {
+	return nil
+}
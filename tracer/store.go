@@ -0,0 +1,204 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------- STORE -------------------------- //
+// a Store is where a tracer saves its messages, so a small edge device can trace without a database
+//
+//	NewTracer("modbus", "modbus-driver", logger, db)                          // sqlite, see InitDatabase()
+//	NewTracerWithStore("modbus", "modbus-driver", logger, NewMemoryStore(1000)) // ring buffer
+//	store, err := NewFileStore("/data/tracer", nil)                             // rotating JSON lines files
+
+// ErrNoStore is returned when a tracer has no store to save or read its messages
+var ErrNoStore = errors.New("database has not been initialised yet")
+
+type Store interface {
+	// SaveTracer adds or updates a tracer, its messages are not saved
+	SaveTracer(tracer *Tracer) error
+	// Tracers returns the saved tracers without their messages
+	Tracers() ([]*Tracer, error)
+	// Append saves the messages, they are saved all or none
+	Append(messages []*Message) error
+	// Query returns one page of the messages that match the query
+	Query(query *MessageQuery) (*MessagePage, error)
+	// Trim deletes the messages of a tracer that are over the retention policy and returns how many were deleted
+	Trim(tracerUUID string, policy *RetentionPolicy, now time.Time) (int64, error)
+	// DeleteTracer deletes a tracer and its messages
+	DeleteTracer(tracerUUID string) error
+	Close() error
+}
+
+// normalize returns the limit and order of a query and the position of its cursor
+func (q *MessageQuery) normalize() (limit int, order string, after *Message, err error) {
+	limit = q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	order = strings.ToLower(q.Order)
+	if order == "" {
+		order = OrderDesc
+	}
+	if order != OrderAsc && order != OrderDesc {
+		return 0, "", nil, fmt.Errorf("order must be %s or %s", OrderAsc, OrderDesc)
+	}
+	if q.Cursor != "" {
+		timestamp, uuid, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		after = &Message{UUID: uuid, Timestamp: timestamp}
+	}
+	return limit, order, after, nil
+}
+
+// newPage returns the first limit messages as a page, messages has one more message than the limit if there is a
+// next page
+func newPage(messages []*Message, limit int) *MessagePage {
+	page := &MessagePage{Messages: messages}
+	if page.Messages == nil {
+		page.Messages = []*Message{}
+	}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		last := page.Messages[limit-1]
+		page.NextCursor = encodeCursor(last.Timestamp, last.UUID)
+	}
+	return page
+}
+
+// ---------------------------- IN MEMORY QUERIES -------------------------- //
+// the stores that do not have a database query and trim the messages in memory
+
+// queryMessages returns one page of the messages that match the query, the tracers are used to filter by the
+// instance and key of a tracer
+func queryMessages(messages []*Message, tracers map[string]*Tracer, query *MessageQuery) (*MessagePage, error) {
+	if query == nil {
+		query = &MessageQuery{}
+	}
+	limit, order, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+	search := strings.ToLower(query.Search)
+	var matched []*Message
+	for _, message := range messages {
+		if query.TracerUUID != "" && message.TracerUUID != query.TracerUUID {
+			continue
+		}
+		if query.InstanceUUID != "" || query.Key != "" {
+			tracer, ok := tracers[message.TracerUUID]
+			if !ok || (query.InstanceUUID != "" && tracer.InstanceUUID != query.InstanceUUID) || (query.Key != "" && tracer.Key != query.Key) {
+				continue
+			}
+		}
+		if query.Path != "" && message.Path != query.Path {
+			continue
+		}
+		if len(query.LoggerTypes) > 0 && !contains(query.LoggerTypes, message.LoggerType) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(message.Text), search) {
+			continue
+		}
+		if !query.From.IsZero() && message.Timestamp.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && !message.Timestamp.Before(query.To) {
+			continue
+		}
+		if after != nil && !(order == OrderDesc && newer(after, message)) && !(order == OrderAsc && newer(message, after)) {
+			continue
+		}
+		matched = append(matched, message)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if order == OrderDesc {
+			return newer(matched[i], matched[j])
+		}
+		return newer(matched[j], matched[i])
+	})
+	if len(matched) > limit+1 {
+		matched = matched[:limit+1]
+	}
+	return newPage(matched, limit), nil
+}
+
+// trimMessages returns the messages that are kept by the retention policy of the tracer and how many were deleted
+func trimMessages(messages []*Message, tracerUUID string, policy *RetentionPolicy, now time.Time) ([]*Message, int64) {
+	kept, deleted := trimTracers(messages, map[string]*RetentionPolicy{tracerUUID: policy}, now)
+	return kept, deleted[tracerUUID]
+}
+
+// trimTracers returns the messages that are kept by the retention policies by tracer uuid in one pass and how many
+// were deleted of each tracer, the messages of a tracer without a policy are kept
+func trimTracers(messages []*Message, policies map[string]*RetentionPolicy, now time.Time) ([]*Message, map[string]int64) {
+	type counter struct {
+		tracerUUID string
+		retention  *Retention
+	}
+	retentionOf := func(message *Message) *Retention {
+		policy := policies[message.TracerUUID]
+		if policy == nil {
+			return nil
+		}
+		if retention, ok := policy.Levels[message.LoggerType]; ok {
+			return retention
+		}
+		return &policy.Retention
+	}
+	// count the messages of each retention of a tracer from the newest to the oldest
+	newestFirst := append([]*Message{}, messages...)
+	sort.Slice(newestFirst, func(i, j int) bool { return newer(newestFirst[i], newestFirst[j]) })
+	counts := map[counter]int{}
+	deleted := map[*Message]bool{}
+	byTracer := map[string]int64{}
+	for _, message := range newestFirst {
+		retention := retentionOf(message)
+		if retention.empty() {
+			continue
+		}
+		key := counter{tracerUUID: message.TracerUUID, retention: retention}
+		counts[key]++
+		if (retention.MaxAge > 0 && message.Timestamp.Before(now.Add(-retention.MaxAge))) ||
+			(retention.MaxCount > 0 && counts[key] > retention.MaxCount) {
+			deleted[message] = true
+			byTracer[message.TracerUUID]++
+		}
+	}
+	if len(deleted) == 0 {
+		return messages, byTracer
+	}
+	kept := make([]*Message, 0, len(messages)-len(deleted))
+	for _, message := range messages {
+		if !deleted[message] {
+			kept = append(kept, message)
+		}
+	}
+	return kept, byTracer
+}
+
+// newer returns true if message a comes after message b
+func newer(a, b *Message) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.After(b.Timestamp)
+	}
+	return a.UUID > b.UUID
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package tracer
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"sqlite": func(t *testing.T) Store {
+			db, err := InitDatabase(filepath.Join(t.TempDir(), "rx.db"), &Tracer{}, &Message{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return NewSQLiteStore(db)
+		},
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore(100)
+		},
+		"file": func(t *testing.T) Store {
+			store, err := NewFileStore(t.TempDir(), &FileStoreOptions{MaxFileSize: 512, MaxFiles: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, newStore(t))
+		})
+	}
+}
+
+func testStore(t *testing.T, store Store) {
+	defer store.Close()
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	modbus := NewTracerWithStore("modbus", "modbus-driver", logger, store)
+	if err := modbus.AddTracer("node-a", "read-coil"); err != nil {
+		t.Fatal(err)
+	}
+	bacnet := NewTracerWithStore("bacnet", "bacnet-server", logger, store)
+	if err := bacnet.AddTracer("node-b", "write"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		modbus.Debugf("read %d", i)
+		modbus.Errorf("timeout %d", i)
+		bacnet.Debugf("write %d", i)
+	}
+	for _, tracer := range []*Tracer{modbus, bacnet} {
+		if err := tracer.SaveMessages(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var texts []string
+	query := &MessageQuery{Key: "read-coil", LoggerTypes: []string{errorType}, Search: "TIME", Limit: 4}
+	for {
+		page, err := modbus.QueryMessages(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, message := range page.Messages {
+			texts = append(texts, message.Text)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if fmt.Sprint(texts) != "[timeout 5 timeout 4 timeout 3 timeout 2 timeout 1 timeout 0]" {
+		t.Fatalf("unexpected messages %v", texts)
+	}
+
+	deleted, err := store.Trim(modbus.UUID, &RetentionPolicy{Retention: Retention{MaxCount: 2}, Levels: map[string]*Retention{errorType: {MaxCount: 3}}}, time.Now())
+	if err != nil || deleted != 7 {
+		t.Fatalf("expected 7 messages to be deleted got %d err: %v", deleted, err)
+	}
+	messages, err := modbus.GetMessagesByTracerUUID(modbus.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts = nil
+	for _, message := range messages {
+		texts = append(texts, message.Text)
+	}
+	if fmt.Sprint(texts) != "[timeout 3 read 4 timeout 4 read 5 timeout 5]" {
+		t.Fatalf("unexpected messages after trim %v", texts)
+	}
+
+	if err := modbus.DeleteTracer(bacnet.UUID); err != nil {
+		t.Fatal(err)
+	}
+	tracers, err := modbus.GetAllTracers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracers) != 1 || tracers[0].UUID != modbus.UUID || tracers[0].InstanceUUID != "node-a" || len(tracers[0].Messages) != 5 {
+		t.Fatalf("unexpected tracers %+v", tracers)
+	}
+}
+
+func TestMemoryStoreRing(t *testing.T) {
+	store := NewMemoryStore(3)
+	for i := 0; i < 5; i++ {
+		store.Append([]*Message{{UUID: fmt.Sprint(i), Text: fmt.Sprint(i), Timestamp: time.Now()}})
+	}
+	page, err := store.Query(&MessageQuery{Order: OrderAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 3 || page.Messages[0].Text != "2" || page.Messages[2].Text != "4" {
+		t.Fatalf("expected the newest 3 messages %+v", page.Messages)
+	}
+}
+
+func TestFileStoreRotate(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, &FileStoreOptions{MaxFileSize: 200, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveTracer(&Tracer{UUID: "t1", InstanceUUID: "node-a"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := store.Append([]*Message{{UUID: fmt.Sprintf("%02d", i), TracerUUID: "t1", Text: "x", Timestamp: time.Now()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "messages*.jsonl"))
+	if len(matches) != 3 {
+		t.Fatalf("expected the current and 2 rotated files got %v", matches)
+	}
+	store.Close()
+
+	// the tracers and the messages of the files that were kept are read back
+	store, err = NewFileStore(dir, &FileStoreOptions{MaxFileSize: 200, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	page, err := store.Query(&MessageQuery{InstanceUUID: "node-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) == 0 || len(page.Messages) >= 20 || page.Messages[0].UUID != "19" {
+		t.Fatalf("expected the newest messages of the kept files got %d", len(page.Messages))
+	}
+}
+
+func TestFileStoreTrim(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, &FileStoreOptions{MaxFileSize: 400, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.SaveTracer(&Tracer{UUID: "t1", InstanceUUID: "node-a"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := store.Append([]*Message{{UUID: fmt.Sprintf("%02d", i), TracerUUID: "t1", Text: "x", Timestamp: now.Add(time.Duration(i) * time.Second)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rotated, _ := filepath.Glob(filepath.Join(dir, "messages.*.jsonl"))
+	if len(rotated) == 0 {
+		t.Fatal("expected the messages to be rotated")
+	}
+	// a crash after the rename keeps a rotated file, its messages are not read twice
+	stale, err := os.ReadFile(rotated[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := store.Trim("t1", &RetentionPolicy{Retention: Retention{MaxCount: 4}}, now)
+	if err != nil || deleted != 6 {
+		t.Fatalf("expected 6 messages to be deleted got %d %v", deleted, err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "messages*"))
+	if len(matches) != 1 || filepath.Base(matches[0]) != "messages.jsonl" {
+		t.Fatalf("expected only the current file to be left got %v", matches)
+	}
+	if err := os.WriteFile(rotated[0], stale, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append([]*Message{
+		{UUID: "10", TracerUUID: "t1", Text: "x", Timestamp: now.Add(10 * time.Second)},
+		{UUID: "11", TracerUUID: "t1", Text: "x", Timestamp: now.Add(11 * time.Second)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	page, err := store.Query(&MessageQuery{InstanceUUID: "node-a", Order: OrderAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, message := range page.Messages {
+		if seen[message.UUID] {
+			t.Fatalf("message %s was read twice", message.UUID)
+		}
+		seen[message.UUID] = true
+	}
+	for _, uuid := range []string{"06", "07", "08", "09", "10", "11"} {
+		if !seen[uuid] {
+			t.Fatalf("expected message %s to be kept got %+v", uuid, page.Messages)
+		}
+	}
+}
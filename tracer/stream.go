@@ -0,0 +1,32 @@
+package tracer
+
+// Subscribe calls fn with each message that is added to the tracer eg; to stream the messages of a node to a
+// websocket. fn is called by the goroutine that adds the message so it must not block. It returns the func that
+// unsubscribes fn.
+func (ms *Tracer) Subscribe(fn func(*Message)) func() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.listeners == nil {
+		ms.listeners = make(map[int]func(*Message))
+	}
+	id := ms.nextListener
+	ms.nextListener++
+	ms.listeners[id] = fn
+	return func() {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		delete(ms.listeners, id)
+	}
+}
+
+func (ms *Tracer) notify(message *Message) {
+	ms.mu.Lock()
+	listeners := make([]func(*Message), 0, len(ms.listeners))
+	for _, fn := range ms.listeners {
+		listeners = append(listeners, fn)
+	}
+	ms.mu.Unlock()
+	for _, fn := range listeners {
+		fn(message)
+	}
+}
@@ -0,0 +1,23 @@
+package tracer
+
+import (
+	"github.com/sirupsen/logrus"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	tracer := NewTracerWithStore("modbus", "modbus-driver", logger, NewMemoryStore(10))
+	var received []string
+	unsubscribe := tracer.Subscribe(func(message *Message) {
+		received = append(received, message.LoggerType+":"+message.Text)
+	})
+	tracer.Errorf("timeout")
+	tracer.Debugf("read")
+	unsubscribe()
+	tracer.Debugf("not received")
+	if len(received) != 2 || received[0] != "error:timeout" || received[1] != "debug:read" {
+		t.Fatalf("unexpected messages %v", received)
+	}
+}
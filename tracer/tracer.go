@@ -1,11 +1,12 @@
 package tracer
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/NubeIO/reactive/helpers"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"sync"
 )
 
 const (
@@ -20,45 +21,71 @@ type Tracer struct {
 	Path            string     // plugin, service name
 	Application     string     // modbus
 	Key             string     // could like modbus read-coil, something common
-	instanceUUID    string     // node uuid
+	InstanceUUID    string     `json:"instanceUUID,omitempty"` // node uuid
 	Messages        []*Message `json:"messages,omitempty" gorm:"constraint:OnDelete:CASCADE"`
 	unsavedMessages []*Message // Store unsaved messages in memory
-	db              *gorm.DB
+	mu              sync.Mutex // guards unsavedMessages and the flusher
+	flushOptions    *FlushOptions
+	flushNow        chan struct{}
+	flushCancel     context.CancelFunc
+	flushDone       chan struct{}
+	flushStats      FlushStats
+	listeners       map[int]func(*Message) // called with each message that is added, see Subscribe()
+	nextListener    int
+	store           Store
 	logger          *logrus.Logger // Logger for logging
 }
 
+// NewTracer returns a tracer that saves its messages in the sqlite db, see InitDatabase()
 func NewTracer(path, application string, logger *logrus.Logger, db *gorm.DB) *Tracer {
+	var store Store
+	if db != nil {
+		store = NewSQLiteStore(db)
+	}
+	return NewTracerWithStore(path, application, logger, store)
+}
+
+// NewTracerWithStore returns a tracer that saves its messages in the store eg; a MemoryStore or a FileStore
+func NewTracerWithStore(path, application string, logger *logrus.Logger, store Store) *Tracer {
 	return &Tracer{
 		Path:            path,
 		Application:     application,
 		Messages:        []*Message{},
 		unsavedMessages: []*Message{},
 		logger:          logger,
-		db:              db,
+		store:           store,
 	}
 }
 
+func (ms *Tracer) GetStore() Store {
+	return ms.store
+}
+
 // GetAllTracers retrieves all tracers from the database.
 func (ms *Tracer) GetAllTracers() ([]*Tracer, error) {
-	var tracers []*Tracer
-	if err := ms.db.Preload("Messages").Find(&tracers).Error; err != nil {
-		return nil, fmt.Errorf("error retrieving tracers: %v", err)
+	if ms.store == nil {
+		return nil, fmt.Errorf("GetAllTracers() %w", ErrNoStore)
+	}
+	tracers, err := ms.store.Tracers()
+	if err != nil {
+		return nil, err
+	}
+	for _, tracer := range tracers {
+		if tracer.Messages, err = ms.allMessages(&MessageQuery{TracerUUID: tracer.UUID}); err != nil {
+			return nil, err
+		}
 	}
 	return tracers, nil
 }
 
 func (ms *Tracer) GetMessagesByTracerUUID(tracerUUID string) ([]*Message, error) {
-	if ms.db == nil {
-		return nil, errors.New("GetMessagesByTracerUUID() database has not been initialised yet")
+	if ms.store == nil {
+		return nil, fmt.Errorf("GetMessagesByTracerUUID() %w", ErrNoStore)
 	}
-
-	var messages []*Message
-
-	// Retrieve messages with the specified tracerUUID from the database
-	if err := ms.db.Where("tracer_uuid = ?", tracerUUID).Find(&messages).Error; err != nil {
+	messages, err := ms.allMessages(&MessageQuery{TracerUUID: tracerUUID})
+	if err != nil {
 		return nil, fmt.Errorf("error retrieving messages for tracerUUID %s: %v", tracerUUID, err)
 	}
-
 	return messages, nil
 }
 
@@ -69,10 +96,13 @@ func (ms *Tracer) AddTracer(instanceUUID, key string) error {
 		Path:         ms.Path,
 		Application:  ms.Application,
 		Key:          key,
-		instanceUUID: instanceUUID,
+		InstanceUUID: instanceUUID,
 	}
-	ms.UUID = tracer.UUID
-	if err := ms.db.Create(tracer).Error; err != nil {
+	ms.UUID, ms.InstanceUUID = tracer.UUID, instanceUUID
+	if ms.store == nil {
+		return fmt.Errorf("AddTracer() %w", ErrNoStore)
+	}
+	if err := ms.store.SaveTracer(tracer); err != nil {
 		return fmt.Errorf("error creating tracer: %v", err)
 	}
 	return nil
@@ -85,7 +115,10 @@ func (ms *Tracer) TracerKey(key string) {
 
 // UpdateTracer updates an existing tracer in the database.
 func (ms *Tracer) UpdateTracer(tracer *Tracer) error {
-	if err := ms.db.Save(tracer).Error; err != nil {
+	if ms.store == nil {
+		return fmt.Errorf("UpdateTracer() %w", ErrNoStore)
+	}
+	if err := ms.store.SaveTracer(tracer); err != nil {
 		return fmt.Errorf("error updating tracer: %v", err)
 	}
 	return nil
@@ -93,7 +126,10 @@ func (ms *Tracer) UpdateTracer(tracer *Tracer) error {
 
 // DeleteTracer deletes a tracer by UUID from the database.
 func (ms *Tracer) DeleteTracer(uuid string) error {
-	if err := ms.db.Where("uuid = ?", uuid).Delete(&Tracer{}).Error; err != nil {
+	if ms.store == nil {
+		return fmt.Errorf("DeleteTracer() %w", ErrNoStore)
+	}
+	if err := ms.store.DeleteTracer(uuid); err != nil {
 		return fmt.Errorf("error deleting tracer: %v", err)
 	}
 	return nil
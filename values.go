@@ -17,22 +17,12 @@ type AllNodeValue struct {
 	Outputs  []*Port `json:"outputs,omitempty"`
 }
 
+// GetAllNodeValues returns the port values of every node in the runtime the node belongs to
 func (n *BaseNode) GetAllNodeValues() []*NodeValue {
-	allNodes := n.GetRuntimeNodes()
-	nodeValues := make([]*NodeValue, len(allNodes))
-	for _, node := range allNodes {
-		nv := node.GetAllPortValues()
-		if nv == nil {
-			continue
-		}
-		portValue := &NodeValue{
-			NodeId:   node.GetID(),
-			NodeUUID: node.GetUUID(),
-			Ports:    nv,
-		}
-		nodeValues = append(nodeValues, portValue)
+	if n.runtime == nil {
+		return nil
 	}
-	return nodeValues
+	return n.runtime.GetAllNodeValues()
 }
 
 func (n *BaseNode) GetAllPortValues() []*Port {
@@ -67,8 +57,9 @@ func (n *BaseNode) GetAllOutputValues() []*Port {
 }
 
 func (n *BaseNode) GetPortValue(portID string) (*Port, error) {
-	lastValues := n.LastValue
-	port, exists := lastValues[portID]
+	n.mux.Lock()
+	port, exists := n.LastValue[portID]
+	n.mux.Unlock()
 	if !exists {
 		return nil, fmt.Errorf("port with ID %s not found", portID)
 	}
@@ -85,23 +76,119 @@ func (n *BaseNode) GetInput(id string) *Port {
 	return nil
 }
 
+func (n *BaseNode) GetOutput(id string) *Port {
+	for _, port := range n.GetOutputs() {
+		if port.ID == id {
+			return port
+		}
+	}
+	return nil
+}
+
+// SetInputValue sets the value of an input, an input with a priority array is written at the DefaultPriority.
+// The input keeps a copy of the value so a value that is also sent in a message is not shared.
 func (n *BaseNode) SetInputValue(id string, value interface{}) {
 	port := n.GetInput(id)
-	if port != nil {
-		port.Value = value
+	if port == nil {
+		return
+	}
+	if port.Priority != nil {
+		n.WriteInputPriority(id, DefaultPriority, value, "")
+		return
+	}
+	n.setInputValue(port, copyValue(value))
+}
+
+// GetInputValue returns the value of an input, or nil if the node has no such input
+func (n *BaseNode) GetInputValue(id string) interface{} {
+	port := n.GetInput(id)
+	if port == nil {
+		return nil
+	}
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return port.Value
+}
+
+func (n *BaseNode) setInputValue(port *Port, value interface{}) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	port.Value = value
+}
+
+// copyValue copies the json, array and bytes values, the other values are not shared when they are copied
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = copyValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = copyValue(item)
+		}
+		return out
+	case []byte:
+		return append([]byte{}, v...)
+	default:
+		return value
 	}
 }
 
 func (n *BaseNode) SetLastValue(port *Port) {
+	n.setLastValue(port)
+	if n.runtime != nil {
+		n.runtime.saveValue(n.UUID, port)
+	}
+}
+
+func (n *BaseNode) setLastValue(port *Port) {
 	n.mux.Lock() // Lock the mutex before accessing the shared resource
 	defer n.mux.Unlock()
-	// Check if the port exists in LastValue
+	n.latchLastValue(port)
+}
+
+// inputPort returns the port kept as the last value of an input for a value sent to it, the port takes the id and
+// the details of the input so a value of a connected output is kept as the input
+func inputPort(input *Port, id string, from *Port) *Port {
+	port := &Port{
+		ID:          id,
+		Name:        from.Name,
+		Value:       from.Value,
+		LastUpdated: from.LastUpdated,
+		WrittenBy:   from.WrittenBy,
+		DataType:    from.DataType,
+		Quality:     from.Quality,
+		Timestamp:   from.Timestamp,
+		Sequence:    from.Sequence,
+	}
+	if input != nil {
+		port.Name, port.Direction, port.DataType, port.Enum = input.Name, input.Direction, input.DataType, input.Enum
+		port.Priority = input.Priority.copy()
+	}
+	return port
+}
+
+// latchLastValue is setLastValue() for a caller that holds n.mux
+func (n *BaseNode) latchLastValue(port *Port) {
+	// the last value is a copy so the port sent in a message is never changed by a later value
 	if existingPort, ok := n.LastValue[port.ID]; ok {
-		// Update the value of the existing port
-		existingPort.Value = port.Value
-		n.LastValue[port.ID] = existingPort
+		updated := *existingPort
+		updated.Value = port.Value
+		updated.LastUpdated = port.LastUpdated
+		updated.WrittenBy = port.WrittenBy
+		updated.Priority = port.Priority.copy()
+		updated.Quality = port.Quality
+		updated.Timestamp = port.Timestamp
+		updated.Sequence = port.Sequence
+		n.LastValue[port.ID] = &updated
 	} else {
 		// If the port doesn't exist, create a new port entry
-		n.LastValue[port.ID] = port
+		created := *port
+		created.Priority = port.Priority.copy()
+		n.LastValue[port.ID] = &created
 	}
 }
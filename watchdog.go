@@ -0,0 +1,191 @@
+package reactive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ---------------------------- WATCHDOG -------------------------- //
+// a watchdog on an input flips the input to stale when it does not get a message within its timeout, eg; a
+// Modbus device that stopped responding. The stale value is sent to the input as a message with QualityStale,
+// so the node and any subscriber of the input topic see it, and the fallback value is used if one is set.
+// The input is good again on the next message that is not stale.
+
+// minWatchdogTick is the shortest interval the watchdogs of a node are checked at
+const minWatchdogTick = 10 * time.Millisecond
+
+type Watchdog struct {
+	Timeout  time.Duration `json:"timeout"`
+	Fallback any           `json:"fallback,omitempty"` // the value the input takes when it is stale, nil keeps the last value
+}
+
+type watchdogState struct {
+	watchdog *Watchdog
+	received time.Time // the last time the input got a message
+	stale    bool
+}
+
+// SetInputWatchdog sets the watchdog of an input, a nil watchdog removes it
+func (n *BaseNode) SetInputWatchdog(id string, watchdog *Watchdog) error {
+	port := n.GetInput(id)
+	if port == nil {
+		return fmt.Errorf("SetInputWatchdog() node %s has no input %s", n.UUID, id)
+	}
+	if watchdog != nil && watchdog.Timeout <= 0 {
+		return errors.New("SetInputWatchdog() timeout must be more than 0")
+	}
+	n.mux.Lock()
+	if watchdog == nil {
+		delete(n.watchdogs, id)
+		port.Watchdog = nil
+		n.mux.Unlock()
+		return nil
+	}
+	if n.watchdogs == nil {
+		n.watchdogs = make(map[string]*watchdogState)
+	}
+	n.watchdogs[id] = &watchdogState{watchdog: watchdog, received: time.Now()}
+	port.Watchdog = watchdog
+	start := n.ctx != nil && n.ctx.Err() == nil && !n.watchdogRunning
+	n.mux.Unlock()
+	if start {
+		n.startWatchdogs()
+	}
+	return nil
+}
+
+// IsStale returns true if the watchdog of the input has timed out
+func (n *BaseNode) IsStale(id string) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	state, ok := n.watchdogs[id]
+	return ok && state.stale
+}
+
+// inputReceived returns the transform of the input subscriptions that feeds the watchdog of the input and keeps
+// the last value sent to the input
+func (n *BaseNode) inputReceived(id string) func(*Message) *Message {
+	return func(msg *Message) *Message {
+		if msg == nil || msg.Port == nil || msg.Port.Quality == QualityStale {
+			return msg
+		}
+		n.mux.Lock()
+		if state, ok := n.watchdogs[id]; ok {
+			state.received = time.Now()
+			state.stale = false
+		}
+		n.mux.Unlock()
+		// the input keeps the last value sent to it eg; for scan mode, a write has already set it
+		if !msg.written {
+			n.setLastValue(inputPort(n.GetInput(id), id, msg.Port))
+		}
+		return msg
+	}
+}
+
+// startWatchdogs checks the watchdogs of the node until the node is stopped, it is started by Start()
+func (n *BaseNode) startWatchdogs() {
+	n.mux.Lock()
+	if len(n.watchdogs) == 0 || n.watchdogRunning {
+		n.mux.Unlock()
+		return
+	}
+	n.watchdogRunning = true
+	for _, state := range n.watchdogs {
+		state.received = time.Now()
+	}
+	n.mux.Unlock()
+	n.Go(func(ctx context.Context) {
+		defer func() {
+			n.mux.Lock()
+			n.watchdogRunning = false
+			n.mux.Unlock()
+		}()
+		timer := time.NewTimer(n.watchdogTick())
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				n.checkWatchdogs()
+				timer.Reset(n.watchdogTick())
+			}
+		}
+	})
+}
+
+// watchdogTick is a quarter of the shortest timeout
+func (n *BaseNode) watchdogTick() time.Duration {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	tick := time.Duration(0)
+	for _, state := range n.watchdogs {
+		if tick == 0 || state.watchdog.Timeout/4 < tick {
+			tick = state.watchdog.Timeout / 4
+		}
+	}
+	if tick < minWatchdogTick {
+		tick = minWatchdogTick
+	}
+	return tick
+}
+
+// checkWatchdogs flips the inputs that timed out to stale and sends them their stale value
+func (n *BaseNode) checkWatchdogs() {
+	now := time.Now()
+	var timedOut []string
+	n.mux.Lock()
+	for id, state := range n.watchdogs {
+		if !state.stale && now.Sub(state.received) >= state.watchdog.Timeout {
+			state.stale = true
+			timedOut = append(timedOut, id)
+		}
+	}
+	n.mux.Unlock()
+	for _, id := range timedOut {
+		n.publishStale(id)
+	}
+}
+
+func (n *BaseNode) publishStale(id string) {
+	input := n.GetInput(id)
+	if input == nil {
+		return
+	}
+	n.mux.Lock()
+	watchdog := input.Watchdog
+	n.mux.Unlock()
+	if watchdog == nil {
+		return
+	}
+	value := n.GetInputValue(id)
+	if last, err := n.GetPortValue(id); err == nil {
+		value = last.Value
+	}
+	if watchdog.Fallback != nil {
+		if fallback, err := input.ConvertValue(watchdog.Fallback); err == nil {
+			value = fallback
+		}
+	}
+	port := &Port{
+		ID:          input.ID,
+		Name:        input.Name,
+		Value:       value,
+		LastUpdated: time.Now().Format(time.RFC3339),
+		Direction:   input.Direction,
+		DataType:    input.DataType,
+		Enum:        input.Enum,
+		Priority:    input.Priority.copy(),
+		Quality:     QualityStale,
+	}
+	n.stamp(port)
+	n.SetLastValue(port)
+	n.EventBus.Publish(n.setPortTopic(id), &Message{
+		Port:     port,
+		NodeUUID: n.GetUUID(),
+		NodeID:   n.GetID(),
+	})
+}
@@ -0,0 +1,64 @@
+package reactive
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInputWatchdog(t *testing.T) {
+	runtime := NewRuntime(nil)
+	source := newTestNode("a", "modbus", runtime.GetEventBus())
+	target := newTestNode("b", "add", runtime.GetEventBus())
+	for _, node := range []Node{source, target} {
+		if err := runtime.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runtime.AddConnection(&Connection{SourceUUID: "a", SourcePort: "out", TargetUUID: "b", TargetPort: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.SetInputWatchdog("in", &Watchdog{Timeout: 50 * time.Millisecond, Fallback: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.SetInputWatchdog("missing", &Watchdog{Timeout: time.Second}); err == nil {
+		t.Fatal("expected an error for an input that does not exist")
+	}
+	if err := runtime.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Shutdown(context.Background())
+
+	receive := func() *Port {
+		t.Helper()
+		select {
+		case msg := <-target.Bus["in"]:
+			return msg.Port
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered")
+		}
+		return nil
+	}
+
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 1.0})
+	if port := receive(); port.Quality != QualityGood || target.IsStale("in") {
+		t.Fatalf("unexpected port %+v", port)
+	}
+	// no more messages so the input goes stale and gets the fallback value
+	port := receive()
+	if port.Quality != QualityStale || port.Value != -1.0 || !target.IsStale("in") {
+		t.Fatalf("expected a stale message with the fallback value, got %+v", port)
+	}
+	if last, _ := target.GetPortValue("in"); last.Quality != QualityStale {
+		t.Fatalf("expected the last value to be stale, got %+v", last)
+	}
+
+	source.PublishMessage(&Port{ID: "out", Name: "out", Value: 2.0})
+	if port := receive(); port.Value != 2.0 || target.IsStale("in") {
+		t.Fatalf("expected the input to recover, got %+v", port)
+	}
+	// the input keeps the value of the connected output as its own last value
+	if last, _ := target.GetPortValue("in"); last.ID != "in" || last.Value != 2.0 || last.Quality != QualityGood {
+		t.Fatalf("expected the last value of the input to recover, got %+v", last)
+	}
+}
@@ -0,0 +1,152 @@
+package reactive
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNodeNotFound    = errors.New("node not found")
+	ErrPortNotFound    = errors.New("port not found")
+	ErrPortNotWritable = errors.New("port is not writable")
+	ErrInvalidValue    = errors.New("invalid value")
+)
+
+// PortWrite is a write of a value to a node input, eg; from the websocket or http api
+type PortWrite struct {
+	NodeUUID  string  `json:"nodeUUID"`
+	PortID    string  `json:"portID"`
+	Value     any     `json:"value"`
+	WrittenBy string  `json:"writtenBy,omitempty"` // who wrote the value eg; an user or an api client
+	Priority  int     `json:"priority,omitempty"`  // the priority to write an input with a priority array at, 0 is DefaultPriority
+	Quality   Quality `json:"quality,omitempty"`   // the quality of the value, the default is good
+}
+
+// WritePort validates the value against the data type of the input and converts it, sets the input value and
+// sends it to the node by publishing it on the topic of the input. Only inputs can be written. An input with a
+// priority array is written at the priority of the write and takes the effective value of the array.
+func (r *Runtime) WritePort(write *PortWrite) (*Port, error) {
+	if write == nil {
+		return nil, fmt.Errorf("WritePort() %w: write can not be empty", ErrInvalidValue)
+	}
+	node := r.GetNode(write.NodeUUID)
+	if node == nil {
+		return nil, fmt.Errorf("WritePort() %w: %s", ErrNodeNotFound, write.NodeUUID)
+	}
+	input := node.GetInput(write.PortID)
+	if input == nil {
+		for _, output := range node.GetOutputs() {
+			if output.ID == write.PortID {
+				return nil, fmt.Errorf("WritePort() %w: %s is an output", ErrPortNotWritable, write.PortID)
+			}
+		}
+		return nil, fmt.Errorf("WritePort() %w: node %s has no input %s", ErrPortNotFound, write.NodeUUID, write.PortID)
+	}
+	value, err := input.ConvertValue(write.Value)
+	if err != nil {
+		return nil, fmt.Errorf("WritePort() %w: input %s is a %s, %s", ErrInvalidValue, write.PortID, input.DataType, err.Error())
+	}
+	port, err := writeInput(node, write, value)
+	if err != nil {
+		return nil, fmt.Errorf("WritePort() %w: %s", ErrInvalidValue, err.Error())
+	}
+	// the write owns the last value of the input, the node does not set it again when it receives the message
+	r.eventBus.Publish(PortTopic(node.GetPluginName(), node.GetID(), node.GetUUID(), port.ID), &Message{
+		Port:     port,
+		NodeUUID: node.GetUUID(),
+		NodeID:   node.GetID(),
+		written:  true,
+	})
+	return port, nil
+}
+
+// writeInput writes a converted value to an input, a node without the hooks gets the value and the last value set
+// one after the other
+func writeInput(node Node, write *PortWrite, value any) (*Port, error) {
+	if hooks, ok := node.(baseNodeHooks); ok {
+		return hooks.writeInput(write, value)
+	}
+	input := node.GetInput(write.PortID)
+	if input == nil {
+		return nil, fmt.Errorf("node %s has no input %s", node.GetUUID(), write.PortID)
+	}
+	if input.Priority != nil {
+		effective, err := node.WriteInputPriority(input.ID, writePriority(write), value, write.WrittenBy)
+		if err != nil {
+			return nil, err
+		}
+		value = effective
+	} else if write.Priority != 0 {
+		return nil, fmt.Errorf("input %s has no priority array", write.PortID)
+	} else {
+		node.SetInputValue(input.ID, value)
+	}
+	port := newWrittenPort(input, write, value)
+	stampPort(node, port)
+	node.SetLastValue(port)
+	return port, nil
+}
+
+// writeInput writes a converted value to an input, the priority array, the input value and the last value are
+// set in one step under the node lock so a concurrent write never sees one without the others
+func (n *BaseNode) writeInput(write *PortWrite, value any) (*Port, error) {
+	n.mux.Lock()
+	var input *Port
+	for _, port := range n.Inputs {
+		if port.ID == write.PortID {
+			input = port
+			break
+		}
+	}
+	if input == nil {
+		n.mux.Unlock()
+		return nil, fmt.Errorf("node %s has no input %s", n.UUID, write.PortID)
+	}
+	if input.Priority != nil {
+		// a nil value relinquishes the priority, the input takes the effective value of the priority array
+		effective, err := input.Priority.Write(writePriority(write), value, write.WrittenBy)
+		if err != nil {
+			n.mux.Unlock()
+			return nil, err
+		}
+		value = effective
+	} else if write.Priority != 0 {
+		n.mux.Unlock()
+		return nil, fmt.Errorf("input %s has no priority array", write.PortID)
+	}
+	// the input keeps its own copy so a change of the published value never changes it
+	input.Value = copyValue(value)
+	port := newWrittenPort(input, write, value)
+	n.stampLocked(port)
+	n.latchLastValue(port)
+	n.mux.Unlock()
+	if n.runtime != nil {
+		n.runtime.saveValue(n.UUID, port)
+	}
+	return port, nil
+}
+
+// writePriority returns the priority of a write, 0 is DefaultPriority
+func writePriority(write *PortWrite) int {
+	if write.Priority == 0 {
+		return DefaultPriority
+	}
+	return write.Priority
+}
+
+// newWrittenPort returns the port sent to a node for a write of an input
+func newWrittenPort(input *Port, write *PortWrite, value any) *Port {
+	return &Port{
+		ID:          input.ID,
+		Name:        input.Name,
+		Value:       value,
+		LastUpdated: time.Now().Format(time.RFC3339),
+		WrittenBy:   write.WrittenBy,
+		Direction:   input.Direction,
+		DataType:    input.DataType,
+		Enum:        input.Enum,
+		Priority:    input.Priority.copy(),
+		Quality:     write.Quality,
+	}
+}
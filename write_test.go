@@ -0,0 +1,105 @@
+package reactive
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWritePort(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan *Message, 1)
+	subscription, err := runtime.GetEventBus().SubscribeChannel(PortTopicByUUID("a", "in"), received, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	port, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "in", Value: "22.5", WrittenBy: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port.Value != 22.5 || port.WrittenBy != "test" || port.LastUpdated == "" {
+		t.Fatalf("unexpected port %+v", port)
+	}
+	if node.GetInputValue("in") != 22.5 {
+		t.Fatal("input value was not set")
+	}
+	select {
+	case msg := <-received:
+		if msg.Port.Value != 22.5 {
+			t.Fatalf("unexpected value %v", msg.Port.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write was not published")
+	}
+
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "in", Value: "abc"}); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "out", Value: 1}); !errors.Is(err, ErrPortNotWritable) {
+		t.Fatalf("expected ErrPortNotWritable, got %v", err)
+	}
+	if _, err := runtime.WritePort(&PortWrite{NodeUUID: "b", PortID: "in", Value: 1}); !errors.Is(err, ErrNodeNotFound) {
+		t.Fatalf("expected ErrNodeNotFound, got %v", err)
+	}
+
+	handler := NewPortWriteHandler(runtime)
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"nodeUUID": "a", "portID": "in", "value": true}`, http.StatusOK},
+		{`{"nodeUUID": "a", "portID": "missing", "value": 1}`, http.StatusNotFound},
+		{`{"nodeUUID": "a", "portID": "in", "value": [1]}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(test.body)))
+		if rec.Code != test.status {
+			t.Fatalf("%s: expected status %d, got %d %s", test.body, test.status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestWritePortConcurrent(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	node.NewInputPort("json", "json", portTypeJSON)
+	if err := runtime.AddNode(node); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "in", Value: float64(i*100 + j)}); err != nil {
+					t.Error(err)
+					return
+				}
+				node.GetInputValue("in")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the input keeps its own copy of a json value
+	port, err := runtime.WritePort(&PortWrite{NodeUUID: "a", PortID: "json", Value: map[string]any{"a": 1.0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port.Value.(map[string]any)["a"] = 2.0
+	if value := node.GetInputValue("json").(map[string]any)["a"]; value != 1.0 {
+		t.Fatalf("input value should not change with the published value, got %v", value)
+	}
+}
@@ -0,0 +1,470 @@
+package reactive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NubeIO/reactive/tracer"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------- WEBSOCKET -------------------------- //
+// a client sends a WSRequest to subscribe to the ports of a node and gets a WSResponse for each message
+//
+//	{"id": "1", "action": "subscribe", "nodeUUID": "abc", "portID": "out"}     portID is optional, empty is all ports
+//	{"id": "2", "action": "unsubscribe", "nodeUUID": "abc", "portID": "out"}
+//	{"id": "3", "action": "write", "nodeUUID": "abc", "portID": "in", "value": 22.5, "priority": 8}  priority is optional
+//	{"id": "4", "action": "trace", "nodeUUID": "abc", "levels": ["error"], "backfill": 50}  tail the tracer of a node
+//	{"id": "5", "action": "untrace", "nodeUUID": "abc"}
+//
+// a trace request is answered with the last backfill messages of the tracer before any live message, a live
+// message that is already in the backfill is not sent again
+
+const (
+	WSActionSubscribe   = "subscribe"
+	WSActionUnsubscribe = "unsubscribe"
+	WSActionWrite       = "write"
+	WSActionTrace       = "trace"
+	WSActionUntrace     = "untrace"
+)
+
+const (
+	WSTypeMessage = "message" // a message published by a port the client subscribed to
+	WSTypeValues  = "values"  // the last values of the ports, sent on subscribe
+	WSTypeTrace   = "trace"   // a message of the tracer of a node the client is tracing
+	WSTypeTraces  = "traces"  // the last messages of the tracer, sent on trace
+	WSTypeAck     = "ack"
+	WSTypeError   = "error"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 64 * 1024
+	wsSendBufferSize = 64
+)
+
+type WSRequest struct {
+	ID       string   `json:"id,omitempty"`
+	Action   string   `json:"action"`
+	NodeUUID string   `json:"nodeUUID"`
+	PortID   string   `json:"portID,omitempty"`
+	Value    any      `json:"value,omitempty"`
+	Priority int      `json:"priority,omitempty"` // the priority of a write, see PortWrite
+	Levels   []string `json:"levels,omitempty"`   // the logger types to trace, empty is all
+	Backfill int      `json:"backfill,omitempty"` // the number of past tracer messages sent on trace
+}
+
+type WSResponse struct {
+	ID       string            `json:"id,omitempty"` // id of the request the response is for
+	Type     string            `json:"type"`
+	Topic    string            `json:"topic,omitempty"`
+	NodeUUID string            `json:"nodeUUID,omitempty"`
+	Message  *Message          `json:"message,omitempty"`
+	Ports    []*Port           `json:"ports,omitempty"`
+	Traces   []*tracer.Message `json:"traces,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type WSConnection struct {
+	Conn          *websocket.Conn
+	Send          chan *WSResponse // Channel to Send messages
+	done          chan struct{}
+	closeOnce     sync.Once
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription // by topic
+}
+
+func NewWSConnection(conn *websocket.Conn) *WSConnection {
+	return &WSConnection{
+		Conn:          conn,
+		Send:          make(chan *WSResponse, wsSendBufferSize),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*Subscription),
+	}
+}
+
+// send waits until the response is queued or the connection is closed
+func (c *WSConnection) send(resp *WSResponse) bool {
+	select {
+	case <-c.done:
+		return false
+	case c.Send <- resp:
+		return true
+	}
+}
+
+// close unsubscribes the connection from the EventBus, the Send channel is not closed so a late send can not panic
+func (c *WSConnection) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for topic, subscription := range c.subscriptions {
+			subscription.Unsubscribe()
+			delete(c.subscriptions, topic)
+		}
+	})
+}
+
+type WSHub struct {
+	Connections map[*WSConnection]bool
+	Register    chan *WSConnection
+	Unregister  chan *WSConnection
+	mu          sync.Mutex // Mutex to protect concurrent access to connections
+}
+
+func NewWSHub() *WSHub {
+	return &WSHub{
+		Connections: make(map[*WSConnection]bool),
+		Register:    make(chan *WSConnection),
+		Unregister:  make(chan *WSConnection),
+	}
+}
+
+func (h *WSHub) Unsubscribe(conn *WSConnection) {
+	h.mu.Lock() // Use a mutex to handle concurrent access
+	defer h.mu.Unlock()
+
+	if _, ok := h.Connections[conn]; ok {
+		delete(h.Connections, conn)
+		conn.close()
+	}
+}
+
+// ConnectionCount returns the number of open connections
+func (h *WSHub) ConnectionCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.Connections)
+}
+
+// Run registers and unregisters connections, a connection gets its messages from its own subscriptions
+func (h *WSHub) Run() {
+	for {
+		select {
+		case conn := <-h.Register:
+			h.mu.Lock()
+			h.Connections[conn] = true
+			h.mu.Unlock()
+		case conn := <-h.Unregister:
+			h.Unsubscribe(conn)
+		}
+	}
+}
+
+// WSHandler is a http.Handler that upgrades a request to a websocket, each connection can subscribe to the
+// ports of the nodes in the runtime and write values to their inputs
+type WSHandler struct {
+	runtime  *Runtime
+	Upgrader websocket.Upgrader
+}
+
+// NewWSHandler returns a handler that only upgrades a request from the same origin as the host, a browser client
+// served from another origin is rejected unless the origins are allowed eg;
+//
+//	handler := NewWSHandler(runtime, "http://localhost:3000")
+func NewWSHandler(runtime *Runtime, allowedOrigins ...string) *WSHandler {
+	h := &WSHandler{
+		runtime: runtime,
+		Upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+	if len(allowedOrigins) > 0 {
+		h.Upgrader.CheckOrigin = AllowOrigins(allowedOrigins...)
+	}
+	return h
+}
+
+// AllowOrigins returns a CheckOrigin func of the websocket.Upgrader that allows a request without an origin, from
+// the same origin as the host or from one of the origins, "*" allows any origin
+func AllowOrigins(origins ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(origins, "*") || slices.Contains(origins, origin) {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+func (h *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader has replied with the error
+	}
+	conn := NewWSConnection(ws)
+	hub := h.runtime.GetEventBus().WS
+	hub.Register <- conn
+	go h.writePump(conn)
+	h.readPump(conn)
+	hub.Unregister <- conn
+}
+
+// readPump handles the requests of the client until the connection is closed
+func (h *WSHandler) readPump(conn *WSConnection) {
+	defer conn.close()
+	conn.Conn.SetReadLimit(wsMaxMessageSize)
+	conn.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.Conn.SetPongHandler(func(string) error {
+		return conn.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := conn.Conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		req := &WSRequest{}
+		if err := json.Unmarshal(data, req); err != nil {
+			conn.send(&WSResponse{Type: WSTypeError, Error: fmt.Sprintf("invalid request: %s", err.Error())})
+			continue
+		}
+		if err := h.handleRequest(conn, req); err != nil {
+			conn.send(&WSResponse{ID: req.ID, Type: WSTypeError, NodeUUID: req.NodeUUID, Error: err.Error()})
+		}
+	}
+}
+
+// writePump writes the queued responses to the socket and pings the client
+func (h *WSHandler) writePump(conn *WSConnection) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Conn.Close()
+	}()
+	for {
+		select {
+		case <-conn.done:
+			conn.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			conn.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case resp := <-conn.Send:
+			conn.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.Conn.WriteJSON(resp); err != nil {
+				conn.close()
+				return
+			}
+		case <-ticker.C:
+			conn.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				conn.close()
+				return
+			}
+		}
+	}
+}
+
+// handleRequest handles a request of the client, an unsubscribe or untrace does not need the node to exist so a
+// client can clean up after a node was deleted
+func (h *WSHandler) handleRequest(conn *WSConnection, req *WSRequest) error {
+	topic := NodeTopic(req.NodeUUID)
+	if req.PortID != "" {
+		topic = PortTopicByUUID(req.NodeUUID, req.PortID)
+	}
+	if req.Action == WSActionTrace || req.Action == WSActionUntrace {
+		topic = TracerTopicByNode(req.NodeUUID)
+	}
+	if req.Action == WSActionUnsubscribe || req.Action == WSActionUntrace {
+		conn.mu.Lock()
+		subscription, ok := conn.subscriptions[topic]
+		delete(conn.subscriptions, topic)
+		conn.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("not subscribed to %s", topic)
+		}
+		subscription.Unsubscribe()
+		conn.send(&WSResponse{ID: req.ID, Type: WSTypeAck, Topic: topic, NodeUUID: req.NodeUUID})
+		return nil
+	}
+	node := h.runtime.GetNode(req.NodeUUID)
+	if node == nil {
+		return fmt.Errorf("node with uuid %s not found", req.NodeUUID)
+	}
+	switch req.Action {
+	case WSActionSubscribe:
+		return h.subscribe(conn, req, node, topic)
+	case WSActionTrace:
+		return h.trace(conn, req, node, topic)
+	case WSActionWrite:
+		if req.PortID == "" {
+			return fmt.Errorf("portID is required to write")
+		}
+		_, err := h.runtime.WritePort(&PortWrite{
+			NodeUUID:  req.NodeUUID,
+			PortID:    req.PortID,
+			Value:     req.Value,
+			WrittenBy: fmt.Sprintf("ws:%s", conn.Conn.RemoteAddr().String()),
+			Priority:  req.Priority,
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown action %s", req.Action)
+	}
+	conn.send(&WSResponse{ID: req.ID, Type: WSTypeAck, Topic: topic, NodeUUID: req.NodeUUID})
+	return nil
+}
+
+// subscribe subscribes the connection to the topic and sends the last values of the ports
+func (h *WSHandler) subscribe(conn *WSConnection, req *WSRequest, node Node, topic string) error {
+	if err := h.subscribeTopic(conn, topic); err != nil {
+		return err
+	}
+	ports := []*Port{}
+	for _, port := range node.GetAllPortValues() {
+		if req.PortID == "" || port.ID == req.PortID {
+			p := *port
+			ports = append(ports, &p)
+		}
+	}
+	conn.send(&WSResponse{ID: req.ID, Type: WSTypeValues, Topic: topic, NodeUUID: req.NodeUUID, Ports: ports})
+	return nil
+}
+
+// subscribeTopic subscribes the connection to the topic once, conn.mu is only held to add the subscription so a
+// slow client does not block the other requests
+func (h *WSHandler) subscribeTopic(conn *WSConnection, topic string) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	select {
+	case <-conn.done:
+		return nil
+	default:
+	}
+	if _, ok := conn.subscriptions[topic]; ok {
+		return nil
+	}
+	subscription, err := h.runtime.GetEventBus().Subscribe(topic, func(msg *Message) {
+		conn.send(&WSResponse{Type: WSTypeMessage, Topic: msg.Topic, NodeUUID: msg.NodeUUID, Message: msg})
+	}, &SubscribeOptions{Policy: DeliveryLatest})
+	if err != nil {
+		return err
+	}
+	conn.subscriptions[topic] = subscription
+	return nil
+}
+
+// trace subscribes the connection to the tracer messages of the node and sends the last messages of the tracer,
+// tracing a node again replaces the levels. The live messages are held back until the backfill is sent.
+func (h *WSHandler) trace(conn *WSConnection, req *WSRequest, node Node, topic string) error {
+	t := node.GetTracer()
+	if t == nil {
+		return fmt.Errorf("node %s has no tracer", req.NodeUUID)
+	}
+	levels := map[string]bool{}
+	for _, level := range req.Levels {
+		levels[level] = true
+	}
+	live := &traceBuffer{}
+	subscribed, err := h.traceTopic(conn, topic, func(msg *Message) {
+		if msg.Trace != nil && (len(levels) == 0 || levels[msg.Trace.LoggerType]) {
+			live.send(conn, &WSResponse{Type: WSTypeTrace, Topic: msg.Topic, NodeUUID: msg.NodeUUID, Message: msg})
+		}
+	})
+	if err != nil || !subscribed {
+		return err
+	}
+	traces, err := traceBackfill(t, node.GetUUID(), req.Levels, req.Backfill)
+	if err != nil {
+		return err
+	}
+	conn.send(&WSResponse{ID: req.ID, Type: WSTypeTraces, Topic: topic, NodeUUID: req.NodeUUID, Traces: traces})
+	live.flush(conn, traces)
+	return nil
+}
+
+// traceTopic replaces the trace subscription of the connection to the topic, conn.mu is only held to swap the
+// subscription. It returns false if the connection is closed.
+func (h *WSHandler) traceTopic(conn *WSConnection, topic string, callback func(msg *Message)) (bool, error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	select {
+	case <-conn.done:
+		return false, nil
+	default:
+	}
+	if subscription, ok := conn.subscriptions[topic]; ok {
+		subscription.Unsubscribe()
+		delete(conn.subscriptions, topic)
+	}
+	subscription, err := h.runtime.GetEventBus().Subscribe(topic, callback)
+	if err != nil {
+		return false, err
+	}
+	conn.subscriptions[topic] = subscription
+	return true, nil
+}
+
+// traceBuffer holds back the live tracer messages until the backfill is sent
+type traceBuffer struct {
+	mu      sync.Mutex
+	ready   bool
+	pending []*WSResponse
+}
+
+func (b *traceBuffer) send(conn *WSConnection, resp *WSResponse) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.ready {
+		b.pending = append(b.pending, resp)
+		return
+	}
+	conn.send(resp)
+}
+
+// flush sends the held back messages that are not in the backfill, the live messages wait until they are sent
+func (b *traceBuffer) flush(conn *WSConnection, backfill []*tracer.Message) {
+	sent := make(map[string]bool, len(backfill))
+	for _, message := range backfill {
+		sent[message.UUID] = true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, resp := range b.pending {
+		if !sent[resp.Message.Trace.UUID] {
+			conn.send(resp)
+		}
+	}
+	b.pending = nil
+	b.ready = true
+}
+
+// traceBackfill returns the last messages of the tracer from the oldest to the newest, the messages that are not
+// saved yet are the newest
+func traceBackfill(t *tracer.Tracer, nodeUUID string, levels []string, backfill int) ([]*tracer.Message, error) {
+	traces := []*tracer.Message{}
+	if backfill <= 0 {
+		return traces, nil
+	}
+	backfill = min(backfill, tracer.MaxQueryLimit)
+	for _, message := range t.GetInMemoryMessages() {
+		if len(levels) == 0 || slices.Contains(levels, message.LoggerType) {
+			traces = append(traces, message)
+		}
+	}
+	if len(traces) < backfill {
+		page, err := t.QueryMessages(&tracer.MessageQuery{InstanceUUID: nodeUUID, LoggerTypes: levels, Limit: backfill - len(traces)})
+		if err != nil && !errors.Is(err, tracer.ErrNoStore) {
+			return nil, err
+		}
+		if page != nil {
+			saved := make([]*tracer.Message, 0, len(page.Messages)+len(traces))
+			for i := len(page.Messages) - 1; i >= 0; i-- {
+				saved = append(saved, page.Messages[i])
+			}
+			traces = append(saved, traces...)
+		}
+	}
+	return traces[max(0, len(traces)-backfill):], nil
+}
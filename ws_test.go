@@ -0,0 +1,195 @@
+package reactive
+
+import (
+	"github.com/NubeIO/reactive/tracer"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readWS(t *testing.T, ws *websocket.Conn) *WSResponse {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	resp := &WSResponse{}
+	if err := ws.ReadJSON(resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestWSHandler(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "add", runtime.GetEventBus())
+	runtime.AddNode(node)
+	node.SetLastValue(&Port{ID: "out", Name: "out", Value: 1.0})
+
+	server := httptest.NewServer(NewWSHandler(runtime))
+	defer server.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	ws.WriteJSON(&WSRequest{ID: "1", Action: WSActionSubscribe, NodeUUID: "a", PortID: "out"})
+	resp := readWS(t, ws)
+	if resp.Type != WSTypeValues || resp.ID != "1" || len(resp.Ports) != 1 || resp.Ports[0].Value != 1.0 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	node.PublishMessage(&Port{ID: "out", Name: "out", Value: 2.0})
+	resp = readWS(t, ws)
+	if resp.Type != WSTypeMessage || resp.Message.Port.Value != 2.0 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	ws.WriteJSON(&WSRequest{ID: "2", Action: WSActionWrite, NodeUUID: "a", PortID: "in", Value: 5.0})
+	if resp = readWS(t, ws); resp.Type != WSTypeAck || resp.ID != "2" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	select {
+	case msg := <-node.Bus["in"]:
+		if msg.Port.Value != 5.0 {
+			t.Fatalf("unexpected value %v", msg.Port.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the written value")
+	}
+
+	ws.WriteJSON(&WSRequest{ID: "3", Action: WSActionSubscribe, NodeUUID: "missing"})
+	if resp = readWS(t, ws); resp.Type != WSTypeError || resp.ID != "3" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	// a client can unsubscribe from a node that was deleted
+	if err := runtime.RemoveNode("a"); err != nil {
+		t.Fatal(err)
+	}
+	ws.WriteJSON(&WSRequest{ID: "4", Action: WSActionUnsubscribe, NodeUUID: "a", PortID: "out"})
+	if resp = readWS(t, ws); resp.Type != WSTypeAck || resp.ID != "4" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestWSTrace(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "modbus", runtime.GetEventBus())
+	runtime.AddNode(node)
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	trace := tracer.NewTracerWithStore("modbus", "modbus-driver", logger, tracer.NewMemoryStore(100))
+	node.InitTracer(trace)
+	trace.Errorf("saved error")
+	trace.Debugf("saved debug")
+	if err := trace.SaveMessages(); err != nil {
+		t.Fatal(err)
+	}
+	trace.Errorf("unsaved error")
+
+	server := httptest.NewServer(NewWSHandler(runtime))
+	defer server.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	ws.WriteJSON(&WSRequest{ID: "1", Action: WSActionTrace, NodeUUID: "a", Levels: []string{"error"}, Backfill: 5})
+	resp := readWS(t, ws)
+	if resp.Type != WSTypeTraces || resp.ID != "1" || len(resp.Traces) != 2 || resp.Traces[0].Text != "saved error" || resp.Traces[1].Text != "unsaved error" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	trace.Debugf("live debug")
+	trace.Errorf("live error")
+	resp = readWS(t, ws)
+	if resp.Type != WSTypeTrace || resp.Topic != TracerTopic("a", "error") || resp.Message.Trace.Text != "live error" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	ws.WriteJSON(&WSRequest{ID: "2", Action: WSActionUntrace, NodeUUID: "a"})
+	if resp = readWS(t, ws); resp.Type != WSTypeAck || resp.ID != "2" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	ws.WriteJSON(&WSRequest{ID: "3", Action: WSActionTrace, NodeUUID: "missing"})
+	if resp = readWS(t, ws); resp.Type != WSTypeError || resp.ID != "3" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestWSTraceBuffer(t *testing.T) {
+	conn := &WSConnection{Send: make(chan *WSResponse, 10), done: make(chan struct{})}
+	live := &traceBuffer{}
+	for _, uuid := range []string{"2", "3"} {
+		live.send(conn, &WSResponse{Type: WSTypeTrace, Message: &Message{Trace: &tracer.Message{UUID: uuid}}})
+	}
+	if len(conn.Send) != 0 {
+		t.Fatal("expected the live messages to be held back until the backfill is sent")
+	}
+	conn.send(&WSResponse{Type: WSTypeTraces})
+	live.flush(conn, []*tracer.Message{{UUID: "1"}, {UUID: "2"}})
+	live.send(conn, &WSResponse{Type: WSTypeTrace, Message: &Message{Trace: &tracer.Message{UUID: "4"}}})
+	var got []string
+	for len(conn.Send) > 0 {
+		resp := <-conn.Send
+		if resp.Type == WSTypeTraces {
+			got = append(got, "traces")
+		} else {
+			got = append(got, resp.Message.Trace.UUID)
+		}
+	}
+	if strings.Join(got, ",") != "traces,3,4" {
+		t.Fatalf("expected the backfill then the live messages that are not in it, got %v", got)
+	}
+}
+
+func TestDeleteStopsTrace(t *testing.T) {
+	runtime := NewRuntime(nil)
+	node := newTestNode("a", "modbus", runtime.GetEventBus())
+	runtime.AddNode(node)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	trace := tracer.NewTracerWithStore("modbus", "modbus-driver", logger, tracer.NewMemoryStore(100))
+	node.InitTracer(trace)
+	received := make(chan *Message, 10)
+	subscription, err := runtime.GetEventBus().Subscribe(TracerTopicByNode("a"), func(msg *Message) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+	if err := node.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	trace.Errorf("after delete")
+	select {
+	case msg := <-received:
+		t.Fatalf("expected no tracer messages after the node was deleted, got %+v", msg.Trace)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWSAllowOrigins(t *testing.T) {
+	runtime := NewRuntime(nil)
+	server := httptest.NewServer(NewWSHandler(runtime, "http://localhost:3000"))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	for origin, allowed := range map[string]bool{
+		"http://localhost:3000": true,
+		server.URL:              true, // same origin as the host
+		"http://example.com":    false,
+	} {
+		ws, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if (err == nil) != allowed {
+			t.Fatalf("origin %s allowed %v, got error %v", origin, allowed, err)
+		}
+		if ws != nil {
+			ws.Close()
+		}
+	}
+}